@@ -2,16 +2,20 @@ package com.norbula.mingxue.service.ai.voice
 
 import com.norbula.mingxue.models.ai.speech.SpeechWord
 import org.slf4j.LoggerFactory
+import org.springframework.beans.factory.annotation.Value
 import org.springframework.stereotype.Service
 import org.springframework.web.reactive.function.client.WebClient
 
 @Service("speech_norbula")
-class NorbulaVoiceGenerator: VoiceGenerator {
+class NorbulaVoiceGenerator(
+    @Value("\${norbula.tts.api.key}") private val ttsApiKey: String
+): VoiceGenerator {
     private val logger = LoggerFactory.getLogger(NorbulaVoiceGenerator::class.java)
 
     private val webClient = WebClient.builder()
         .baseUrl("http://mingxue-tts-server:8081/api/v1")
         .defaultHeader("Content-Type", "application/json")
+        .defaultHeader("X-API-Key", ttsApiKey)
         .build()
 
     override fun generateTTSWordFiles(words: List<SpeechWord>) {
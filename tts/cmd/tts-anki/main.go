@@ -0,0 +1,134 @@
+// Command tts-anki adds synthesized audio to the notes of an existing Anki
+// collection through the AnkiConnect add-on.
+//
+//	tts-anki -query 'deck:Mandarin' -text-field Hanzi -pronunciation-field Pinyin -audio-field Audio
+//
+// Notes whose audio field already has a [sound:] tag are left alone unless
+// -overwrite is given. Providers are configured as for the service, with
+// TTS_CONFIG and the environment.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"tts/src/anki"
+	"tts/src/config"
+	"tts/src/logging"
+	"tts/src/synthesis"
+	"tts/src/tts"
+)
+
+// version identifies the build, set with -ldflags "-X main.version=..."
+var version = "dev"
+
+func main() {
+	connectURL := flag.String("anki-url", anki.DefaultConnectURL, "AnkiConnect address")
+	connectKey := flag.String("anki-key", os.Getenv("ANKICONNECT_KEY"), "AnkiConnect API key, if the add-on requires one")
+	query := flag.String("query", "", "Anki search selecting the notes, such as deck:Mandarin")
+	textField := flag.String("text-field", "Hanzi", "field holding the text to speak")
+	pronunciationField := flag.String("pronunciation-field", "", "field holding numbered pinyin, optional")
+	audioField := flag.String("audio-field", "Audio", "field receiving the [sound:] tag")
+	engineName := flag.String("engine", "", "azure or google, defaults to the configured default provider")
+	gender := flag.String("gender", "any", "male, female or any")
+	voice := flag.String("voice", "", "voice name, overrides -gender")
+	rate := flag.Float64("rate", 0, "speaking rate change in percent, -50 to 100")
+	overwrite := flag.Bool("overwrite", false, "replace the audio of notes that already have some")
+	dryRun := flag.Bool("dry-run", false, "list the notes that would get audio without changing anything")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	flag.Parse()
+
+	logging.Setup(*logLevel)
+
+	if *query == "" {
+		fmt.Fprintln(os.Stderr, "-query is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *rate < -50 || *rate > 100 {
+		fmt.Fprintln(os.Stderr, "-rate must be between -50 and 100")
+		os.Exit(2)
+	}
+	if *gender != "male" && *gender != "female" && *gender != "any" {
+		fmt.Fprintln(os.Stderr, "-gender must be male, female or any")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := anki.NewClient(*connectURL, *connectKey)
+	if _, err := client.Version(ctx); err != nil {
+		fatal("anki is not reachable, is it running with AnkiConnect installed?", err)
+	}
+
+	var synthesize anki.Synthesizer
+	if !*dryRun {
+		cfg, err := config.LoadForCommand()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		provider := *engineName
+		if provider == "" {
+			provider = cfg.DefaultProvider
+		}
+		if !cfg.ProviderEnabled(provider) {
+			fatal("provider is not enabled", fmt.Errorf("%q", provider))
+		}
+		// Previews are never stored, so the engine needs no clip store
+		engine, err := synthesis.NewEngine(provider, cfg, nil, synthesis.Options{Version: version})
+		if err != nil {
+			fatal("failed to create engine", err, "provider", provider)
+		}
+		defer engine.Close()
+		if err := engine.CheckVoice(*voice); err != nil {
+			fatal("invalid voice", err)
+		}
+		synthesize = synthesizer(engine, *voice, *gender, *rate)
+	}
+
+	report, err := anki.Sync(ctx, client, synthesize, anki.SyncOptions{
+		Query:              *query,
+		TextField:          *textField,
+		PronunciationField: *pronunciationField,
+		AudioField:         *audioField,
+		Overwrite:          *overwrite,
+		DryRun:             *dryRun,
+	})
+	slog.Info("sync finished", "matched", report.Matched, "updated", report.Updated, "skipped", report.Skipped,
+		"failed", report.Failed, "dry_run", *dryRun)
+	if err != nil {
+		fatal("sync stopped", err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// synthesizer speaks note text with engine. Pronunciations that are not
+// numbered pinyin, such as tone marks, are left out rather than failing the note.
+func synthesizer(engine *synthesis.Engine, voice, gender string, rate float64) anki.Synthesizer {
+	pinyin := regexp.MustCompile(tts.PronunciationPattern)
+	options := tts.SynthesisOptions{}
+	if rate != 0 {
+		options.RatePercent = &rate
+	}
+	return func(ctx context.Context, text, pronunciation string) ([]byte, error) {
+		if pronunciation != "" && !pinyin.MatchString(pronunciation) {
+			slog.Debug("ignoring pronunciation that is not numbered pinyin", "text", text, "pronunciation", pronunciation)
+			pronunciation = ""
+		}
+		return engine.Preview(ctx, tts.Word{Text: text, Pronunciation: pronunciation}, voice, gender, options)
+	}
+}
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
+}
@@ -0,0 +1,74 @@
+// Command tts-backfill records clips the service's manifest does not know of,
+// such as those stored before the manifest was enabled or copied in with
+// tts-migrate or tts-batch -to.
+//
+//	TTS_CONFIG=/config/tts.yaml tts-backfill
+//	TTS_CONFIG=/config/tts.yaml tts-backfill -prefix tenants/acme/ -dry-run
+//
+// Storage and manifest are the ones the service is configured with. Clips
+// already recorded are left alone, so it can be run again at any time.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"tts/src/config"
+	"tts/src/logging"
+	"tts/src/storage"
+)
+
+func main() {
+	prefix := flag.String("prefix", "", "only record clips under this prefix, such as tts/ or tenants/acme/")
+	dryRun := flag.Bool("dry-run", false, "count the clips that would be recorded without writing anything")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	flag.Parse()
+
+	logging.Setup(*logLevel)
+
+	// Only storage and the manifest are used, so no provider needs credentials
+	cfg, err := config.Read()
+	if err == nil {
+		err = cfg.ValidateStorage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.Manifest.Driver == "" {
+		fmt.Fprintln(os.Stderr, "no manifest is configured, set manifest.driver and manifest.dsn")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db, err := storage.OpenConfig(cfg.Storage)
+	if err != nil {
+		fatal("failed to open storage", err)
+	}
+	manifest, err := storage.OpenManifest(ctx, cfg.Manifest.Driver, cfg.Manifest.DSN)
+	if err != nil {
+		fatal("failed to open manifest", err)
+	}
+	defer manifest.Close()
+
+	report, err := manifest.Backfill(ctx, db, *prefix, *dryRun)
+	slog.Info("backfill finished", "listed", report.Listed, "recorded", report.Recorded, "skipped", report.Skipped,
+		"failed", report.Failed, "dry_run", *dryRun)
+	if err != nil {
+		fatal("backfill stopped", err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"tts/src/tts"
+)
+
+// Row is one word of the input and, optionally, its example sentence. The
+// sentence clip is stored under the same context id as the word.
+type Row struct {
+	Line          int
+	Id            int
+	Text          string
+	Pronunciation string
+	Sentence      string
+}
+
+// columns are the input fields in the order used when there is no header
+var columns = []string{"id", "text", "pronunciation", "sentence"}
+
+// utf8BOM is the byte order mark some editors start UTF-8 files with
+const utf8BOM = "\ufeff"
+
+// columnAliases accepts the names the backend's exports use
+var columnAliases = map[string]string{"context_id": "id", "pinyin": "pronunciation"}
+
+// readRows parses a CSV or TSV word list. The delimiter is a tab for .tsv
+// files and a comma otherwise, unless given. A first non-blank line whose id
+// is not a number is a header naming the columns, in any order.
+func readRows(path string, delimiter rune) ([]Row, error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input: %w", err)
+		}
+		defer file.Close()
+		in = file
+	}
+	if delimiter == 0 {
+		delimiter = ','
+		if strings.EqualFold(filepath.Ext(path), ".tsv") {
+			delimiter = '\t'
+		}
+	}
+
+	// Spreadsheets often save UTF-8 with a byte order mark, which would
+	// otherwise stick to the first column name
+	buffered := bufio.NewReader(in)
+	if bom, err := buffered.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		buffered.Discard(len(utf8BOM))
+	}
+	in = buffered
+
+	var read func() ([]string, error)
+	if delimiter == '\t' {
+		// Word lists exported as TSV do not quote fields but may contain quotes
+		read = tsvReader(in)
+	} else {
+		reader := csv.NewReader(in)
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		read = reader.Read
+	}
+
+	pinyin := regexp.MustCompile(tts.PronunciationPattern)
+	index := map[string]int{}
+	for i, name := range columns {
+		index[name] = i
+	}
+
+	var rows []Row
+	var errs []error
+	first := true
+	// seen is the line each id was first read on, as rows sharing an id would
+	// overwrite each other's clips
+	seen := make(map[int]int)
+	for line := 1; ; line++ {
+		record, err := read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+
+		if first {
+			first = false
+			if _, err := strconv.Atoi(strings.TrimSpace(record[0])); err != nil {
+				index, err = headerIndex(record)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		field := func(name string) string {
+			i, ok := index[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		row := Row{Line: line, Text: field("text"), Pronunciation: field("pronunciation"), Sentence: field("sentence")}
+		row.Id, err = strconv.Atoi(field("id"))
+		switch {
+		case err != nil || row.Id < 0:
+			errs = append(errs, fmt.Errorf("line %d: id must be a non-negative number", line))
+		case row.Text == "":
+			errs = append(errs, fmt.Errorf("line %d: text is empty", line))
+		case row.Pronunciation != "" && !pinyin.MatchString(row.Pronunciation):
+			errs = append(errs, fmt.Errorf("line %d: pronunciation %q is not numbered pinyin", line, row.Pronunciation))
+		case seen[row.Id] != 0:
+			errs = append(errs, fmt.Errorf("line %d: id %d is already used on line %d", line, row.Id, seen[row.Id]))
+		default:
+			seen[row.Id] = line
+			rows = append(rows, row)
+		}
+	}
+	return rows, errors.Join(errs...)
+}
+
+// tsvReader splits each line of in on tabs, taking quotes literally
+func tsvReader(in io.Reader) func() ([]string, error) {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return func() ([]string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, io.EOF
+		}
+		return strings.Split(strings.TrimSuffix(scanner.Text(), "\r"), "\t"), nil
+	}
+}
+
+// headerIndex maps column names to their position in a header line
+func headerIndex(header []string) (map[string]int, error) {
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, utf8BOM)))
+		if alias, ok := columnAliases[name]; ok {
+			name = alias
+		}
+		index[name] = i
+	}
+	if _, ok := index["id"]; !ok {
+		return nil, errors.New("header has no id column")
+	}
+	if _, ok := index["text"]; !ok {
+		return nil, errors.New("header has no text column")
+	}
+	return index, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"tts/src/synthesis"
+)
+
+// Entry records the outcome of one clip. The manifest is appended to as
+// batches finish, and a later entry for the same clip replaces earlier ones.
+type Entry struct {
+	Id            int    `json:"context_id"`
+	Kind          string `json:"kind"`
+	Text          string `json:"text"`
+	Pronunciation string `json:"pronunciation,omitempty"`
+	// Engine, Gender, Voice and RatePercent are the settings the clip was
+	// synthesized with, so a run with other settings does it again
+	Engine      string   `json:"engine"`
+	Gender      string   `json:"gender,omitempty"`
+	Voice       string   `json:"voice,omitempty"`
+	RatePercent *float64 `json:"rate_percent,omitempty"`
+	// Store names the storage written to. With Path it identifies
+	// the clip, so runs into other namespaces or stores can share a manifest.
+	Store  string `json:"store"`
+	Path   string `json:"path"`
+	URL    string `json:"url,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (e Entry) key() string {
+	return e.Store + " " + e.Path
+}
+
+// Manifest is the JSON lines file of a run, read back to resume it
+type Manifest struct {
+	file    *os.File
+	entries map[string]Entry
+}
+
+// OpenManifest reads the entries of an earlier run at path, if any, and
+// appends to it. A line cut short by an interrupted write is ignored.
+func OpenManifest(path string) (*Manifest, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+
+	m := &Manifest{file: file, entries: make(map[string]Entry)}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	torn := false
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			torn = true
+			continue
+		}
+		torn = false
+		m.entries[entry.key()] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	// Start on a fresh line so the next entry is not glued to a torn one
+	if torn {
+		if _, err := file.WriteString("\n"); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to repair manifest: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Done reports whether an earlier run already stored the clip for entry with
+// the same text, pronunciation and synthesis settings
+func (m *Manifest) Done(entry Entry) bool {
+	previous, ok := m.entries[entry.key()]
+	return ok && previous.Status == synthesis.StageUploaded &&
+		previous.Text == entry.Text && previous.Pronunciation == entry.Pronunciation &&
+		previous.Engine == entry.Engine && previous.Gender == entry.Gender && previous.Voice == entry.Voice &&
+		sameRate(previous.RatePercent, entry.RatePercent)
+}
+
+func sameRate(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// Add records entries and syncs them to disk, so a crash loses no finished batch
+func (m *Manifest) Add(entries ...Entry) error {
+	var errs []error
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to encode manifest entry: %w", err))
+			continue
+		}
+		if _, err := m.file.Write(append(line, '\n')); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write manifest: %w", err))
+			continue
+		}
+		m.entries[entry.key()] = entry
+	}
+	if err := m.file.Sync(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to sync manifest: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manifest) Close() error {
+	return m.file.Close()
+}
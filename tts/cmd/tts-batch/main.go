@@ -0,0 +1,299 @@
+// Command tts-batch synthesizes a CSV or TSV word list without the server.
+//
+//	tts-batch -in words.csv -out ./audio
+//	tts-batch -in words.tsv -to azure://tts-audio -engine google -voice cmn-CN-Wavenet-A
+//	TTS_CONFIG=/config/tts.yaml tts-batch -in words.csv -namespace acme
+//
+// Each line holds id, text, pronunciation and an optional example sentence,
+// or any order of those columns under a header line. Clips are stored like
+// the service stores them, under tts/word/<id>.wav and tts/sentence/<id>.wav.
+// Every clip's outcome is appended to the manifest, and running the same
+// command again skips clips already stored at the same path in the same
+// storage with the same text, engine, voice and rate. Ids must be unique.
+// Clips stored in the service's configured storage are also recorded in its
+// manifest, if it has one. Those written with -to are not; run tts-backfill
+// when -to is a store the service uses.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"tts/src/config"
+	"tts/src/logging"
+	"tts/src/namespace"
+	"tts/src/storage"
+	"tts/src/synthesis"
+	"tts/src/tts"
+	"unicode/utf8"
+)
+
+// version identifies the build in clip metadata, set with -ldflags "-X main.version=..."
+var version = "dev"
+
+// options are the synthesis settings of a run
+type options struct {
+	gender    string
+	voice     string
+	rate      *float64
+	batchSize int
+	// store names the storage clips go to
+	store string
+}
+
+func main() {
+	in := flag.String("in", "", "CSV or TSV word list, - for stdin")
+	delimiter := flag.String("delimiter", "", "field separator, defaults to a tab for .tsv files and a comma otherwise")
+	out := flag.String("out", "", "directory to write clips to")
+	to := flag.String("to", "", "storage URL to write clips to, azure://<container> or file:///<dir>; "+
+		"without -out or -to clips go to the storage configured for the service")
+	manifestPath := flag.String("manifest", "", "JSON lines file recording each clip, defaults to manifest.jsonl in -out")
+	engineName := flag.String("engine", "", "azure or google, defaults to the configured default provider")
+	gender := flag.String("gender", "any", "male, female or any")
+	voice := flag.String("voice", "", "voice name, overrides -gender")
+	rate := flag.Float64("rate", 0, "speaking rate change in percent, -50 to 100")
+	batchSize := flag.Int("batch-size", 20, "words synthesized per provider request, 1 synthesizes word by word")
+	ns := flag.String("namespace", namespace.Default, "namespace to store clips in")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	flag.Parse()
+
+	logging.Setup(*logLevel)
+
+	if *in == "" || (*out != "" && *to != "") {
+		fmt.Fprintln(os.Stderr, "-in is required, with at most one of -out or -to")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !namespace.Valid(*ns) {
+		fmt.Fprintf(os.Stderr, "invalid namespace %q\n", *ns)
+		os.Exit(2)
+	}
+	if *rate < -50 || *rate > 100 || *batchSize < 1 {
+		fmt.Fprintln(os.Stderr, "-rate must be between -50 and 100 and -batch-size at least 1")
+		os.Exit(2)
+	}
+	if *gender != "male" && *gender != "female" && *gender != "any" {
+		fmt.Fprintln(os.Stderr, "-gender must be male, female or any")
+		os.Exit(2)
+	}
+	var comma rune
+	if *delimiter != "" {
+		if utf8.RuneCountInString(*delimiter) != 1 && *delimiter != `\t` {
+			fmt.Fprintln(os.Stderr, "-delimiter must be a single character")
+			os.Exit(2)
+		}
+		comma, _ = utf8.DecodeRuneInString(*delimiter)
+		if *delimiter == `\t` {
+			comma = '\t'
+		}
+	}
+
+	rows, err := readRows(*in, comma)
+	if err != nil {
+		fatal("invalid input", err)
+	}
+
+	cfg, err := config.LoadForCommand()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// store names the storage in the manifest, so runs into different stores
+	// can share one
+	var db storage.BlobDatabase
+	var store string
+	switch {
+	case *out != "":
+		var root string
+		root, err = filepath.Abs(*out)
+		if err != nil {
+			fatal("invalid output directory", err)
+		}
+		store = "file://" + filepath.ToSlash(root)
+		db, err = storage.NewFileBlobDatabase(storage.FileBlobOptions{Root: root, BaseURL: store})
+		if *manifestPath == "" {
+			*manifestPath = filepath.Join(root, "manifest.jsonl")
+		}
+	case *to != "":
+		store = *to
+		db, err = storage.OpenURL(*to)
+	default:
+		db, err = storage.OpenConfig(cfg.Storage)
+		store = cfg.Storage.Azure.BlobURL + "/" + cfg.Storage.Azure.ContainerName
+		if cfg.Storage.Backend == "filesystem" {
+			store = "file://" + filepath.ToSlash(cfg.Storage.Filesystem.Root)
+		}
+	}
+	if err != nil {
+		fatal("failed to open storage", err)
+	}
+	if *manifestPath == "" {
+		*manifestPath = "tts-batch.manifest.jsonl"
+	}
+
+	manifest, err := OpenManifest(*manifestPath)
+	if err != nil {
+		fatal("failed to open manifest", err)
+	}
+	defer manifest.Close()
+
+	provider := *engineName
+	if provider == "" {
+		provider = cfg.DefaultProvider
+	}
+	if !cfg.ProviderEnabled(provider) {
+		fatal("provider is not enabled", fmt.Errorf("%q", provider))
+	}
+	// Only the service's own storage is known to belong to its manifest
+	var clipManifest *storage.Manifest
+	if *out == "" && *to == "" && cfg.Manifest.Driver != "" {
+		clipManifest, err = storage.OpenManifest(context.Background(), cfg.Manifest.Driver, cfg.Manifest.DSN)
+		if err != nil {
+			fatal("failed to open service manifest", err)
+		}
+		defer clipManifest.Close()
+	}
+	engine, err := synthesis.NewEngine(provider, cfg, storage.NewClipStore(db, cfg.Storage.KeepVersions, clipManifest),
+		synthesis.Options{Version: version})
+	if err != nil {
+		fatal("failed to create engine", err, "provider", provider)
+	}
+	defer engine.Close()
+	if err := engine.CheckVoice(*voice); err != nil {
+		fatal("invalid voice", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = namespace.With(ctx, *ns)
+
+	opts := options{gender: *gender, voice: *voice, batchSize: *batchSize, store: store}
+	if *rate != 0 {
+		opts.rate = rate
+	}
+
+	var words, sentences []tts.Word
+	for _, row := range rows {
+		words = append(words, tts.Word{Id: row.Id, Text: row.Text, Pronunciation: row.Pronunciation})
+		if row.Sentence != "" {
+			sentences = append(sentences, tts.Word{Id: row.Id, Text: row.Sentence})
+		}
+	}
+
+	var report runReport
+	for _, kind := range []struct {
+		words    []tts.Word
+		sentence bool
+	}{{words, false}, {sentences, true}} {
+		if err := run(ctx, engine, manifest, kind.words, kind.sentence, opts, &report); err != nil {
+			fatal("batch stopped", err, "stored", report.stored, "skipped", report.skipped, "failed", report.failed)
+		}
+	}
+
+	slog.Info("batch finished", "stored", report.stored, "skipped", report.skipped, "failed", report.failed,
+		"manifest", *manifestPath)
+	if report.failed > 0 {
+		os.Exit(1)
+	}
+}
+
+type runReport struct {
+	stored, skipped, failed int
+}
+
+// run synthesizes and stores the words of one kind that the manifest does not
+// list as done, recording each outcome. It stops only when ctx is done or the
+// manifest cannot be written.
+func run(ctx context.Context, engine *synthesis.Engine, manifest *Manifest, words []tts.Word, sentence bool, opts options, report *runReport) error {
+	var pending []tts.Word
+	for _, word := range words {
+		if manifest.Done(entryFor(ctx, engine, word, sentence, opts)) {
+			report.skipped++
+			continue
+		}
+		pending = append(pending, word)
+	}
+
+	for start := 0; start < len(pending); start += opts.batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		batch := pending[start:min(start+opts.batchSize, len(pending))]
+
+		entries := make([]Entry, len(batch))
+		for i, word := range batch {
+			entries[i] = entryFor(ctx, engine, word, sentence, opts)
+		}
+
+		// A chosen voice or rate is only available word by word
+		if len(batch) == 1 || opts.voice != "" || opts.rate != nil {
+			// A spent budget is reported on each word it failed
+			results, _ := engine.Regenerate(ctx, batch, opts.voice, opts.gender, sentence, tts.SynthesisOptions{RatePercent: opts.rate}, nil)
+			for i, result := range results {
+				entries[i].URL, entries[i].Error = result.URL, result.Error
+				entries[i].Status = synthesis.StageUploaded
+				if result.Error != "" {
+					entries[i].Status = synthesis.StageFailed
+				}
+			}
+		} else {
+			urls, err := engine.BatchProcessWords(ctx, batch, opts.gender, sentence, nil)
+			for i := range entries {
+				switch {
+				case i < len(urls):
+					entries[i].URL, entries[i].Status = urls[i], synthesis.StageUploaded
+				case err != nil:
+					entries[i].Status, entries[i].Error = synthesis.StageFailed, err.Error()
+				default:
+					entries[i].Status, entries[i].Error = synthesis.StageFailed, synthesis.ErrNoSegment.Error()
+				}
+			}
+		}
+
+		for _, entry := range entries {
+			if entry.Status == synthesis.StageUploaded {
+				report.stored++
+			} else {
+				report.failed++
+				slog.Error("failed to synthesize", "kind", entry.Kind, "id", entry.Id, "error", entry.Error)
+			}
+		}
+		if err := manifest.Add(entries...); err != nil {
+			return err
+		}
+		slog.Info("batch progress", "kind", synthesis.ClipKind(sentence), "done", start+len(batch), "of", len(pending))
+	}
+	return nil
+}
+
+// entryFor is the manifest entry of a word before it is synthesized
+func entryFor(ctx context.Context, engine *synthesis.Engine, word tts.Word, sentence bool, opts options) Entry {
+	entry := Entry{
+		Id:            word.Id,
+		Kind:          synthesis.ClipKind(sentence),
+		Text:          word.Text,
+		Pronunciation: word.Pronunciation,
+		Engine:        engine.Name(),
+		Voice:         opts.voice,
+		RatePercent:   opts.rate,
+		Store:         opts.store,
+		Path:          synthesis.AudioPath(ctx, strconv.Itoa(word.Id), sentence),
+	}
+	// The gender only picks the voice when none is named
+	if opts.voice == "" {
+		entry.Gender = opts.gender
+	}
+	return entry
+}
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
+}
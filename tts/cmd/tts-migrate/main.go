@@ -0,0 +1,136 @@
+// Command tts-migrate copies stored clips between blob stores or key layouts.
+//
+//	tts-migrate -from azure://tts-audio -to file:///data/tts-audio
+//	tts-migrate -from azure://tts-audio -to azure://tts-audio -rewrite tts/=tenants/acme/tts/
+//	tts-migrate -to file:///data/tts-audio -verify
+//	tts-migrate -from azure://tts-audio -to file:///backup -archive -transcode ogg
+//
+// Copied blobs are recorded in the checkpoint file, so running the same
+// command again after an interruption only copies what is left.
+// The service's manifest is not updated; run tts-backfill after copying into
+// the store the service uses.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"tts/src/logging"
+	"tts/src/migrate"
+	"tts/src/storage"
+)
+
+// rewriteFlags collects repeated -rewrite old=new prefix rules
+type rewriteFlags []migrate.Rewriter
+
+func (r *rewriteFlags) String() string {
+	return ""
+}
+
+func (r *rewriteFlags) Set(value string) error {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok || from == "" {
+		return fmt.Errorf("expected old=new, got %q", value)
+	}
+	*r = append(*r, migrate.ReplacePrefix(from, to))
+	return nil
+}
+
+func main() {
+	var rewrites rewriteFlags
+	from := flag.String("from", "", "source storage URL, azure://<container> or file:///<dir>")
+	to := flag.String("to", "", "destination storage URL")
+	prefix := flag.String("prefix", "tts/", "only copy blobs under this prefix")
+	flag.Var(&rewrites, "rewrite", "rename blobs under a prefix, as old=new (repeatable)")
+	contentAddressed := flag.String("content-addressed", "", "name blobs after their SHA-256 under this prefix, after any -rewrite")
+	format := flag.String("transcode", "", "convert .wav clips to "+strings.Join(migrate.Formats(), ", "))
+	ffmpeg := flag.String("ffmpeg", "", "ffmpeg binary, defaults to FFMPEG_PATH or the PATH")
+	checkpoint := flag.String("checkpoint", "tts-migrate.checkpoint", "file recording copied blobs and their checksums")
+	parallel := flag.Int("parallel", 8, "blobs copied at once")
+	dryRun := flag.Bool("dry-run", false, "read and convert every blob without writing anything")
+	verify := flag.Bool("verify", false, "only check the destination against the checkpoint checksums")
+	archive := flag.Bool("archive", false, "the destination is an archive the service does not read, "+
+		"required for -transcode and -content-addressed")
+	logLevel := flag.String("log-level", "info", "debug, info, warn or error")
+	flag.Parse()
+
+	logging.Setup(*logLevel)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *to == "" || (*from == "" && !*verify) {
+		fmt.Fprintln(os.Stderr, "-from and -to are required, or -to with -verify")
+		flag.Usage()
+		os.Exit(2)
+	}
+	// The service only finds clips as tts/<kind>/<id>.wav, so other names are
+	// lost to it
+	if (*format != "" || *contentAddressed != "") && !*archive {
+		fmt.Fprintln(os.Stderr, "-transcode and -content-addressed write keys the service cannot read, "+
+			"add -archive if the destination is not used by the service")
+		os.Exit(2)
+	}
+	destination, err := storage.OpenURL(*to)
+	if err != nil {
+		fatal("failed to open destination", err)
+	}
+
+	if *verify {
+		report, err := migrate.Verify(ctx, destination, *checkpoint, *parallel)
+		if err != nil {
+			fatal("verification failed", err)
+		}
+		for _, name := range report.Missing {
+			slog.Error("blob missing", "blob", name)
+		}
+		for _, name := range report.Mismatched {
+			slog.Error("checksum mismatch", "blob", name)
+		}
+		slog.Info("verified", "checked", report.Checked, "missing", len(report.Missing), "mismatched", len(report.Mismatched))
+		if len(report.Missing) > 0 || len(report.Mismatched) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	source, err := storage.OpenURL(*from)
+	if err != nil {
+		fatal("failed to open source", err)
+	}
+
+	options := migrate.Options{
+		Prefix:      *prefix,
+		Rewrite:     rewrites,
+		Checkpoint:  *checkpoint,
+		Parallelism: *parallel,
+		DryRun:      *dryRun,
+	}
+	options.ContentAddressed = *contentAddressed
+	if *format != "" {
+		options.Transcoder, err = migrate.NewTranscoder(*ffmpeg, *format)
+		if err != nil {
+			fatal("failed to set up transcoding", err)
+		}
+	}
+
+	report, err := migrate.Copy(ctx, source, destination, options)
+	slog.Info("migration finished", "listed", report.Listed, "copied", report.Copied, "skipped", report.Skipped,
+		"failed", report.Failed, "bytes", report.Bytes, "dry_run", *dryRun)
+	if err != nil {
+		fatal("migration stopped", err)
+	}
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// decodeWAV returns the samples of a 16-bit mono PCM WAV file. Data without a
+// RIFF header is taken as raw samples at rawRate, as the providers return it.
+func decodeWAV(data []byte, rawRate int) ([]int16, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return decodePCM(data), rawRate, nil
+	}
+
+	var sampleRate int
+	var pcm []byte
+	for rest := data[12:]; len(rest) >= 8; {
+		id, size := string(rest[0:4]), int(binary.LittleEndian.Uint32(rest[4:8]))
+		body := rest[8:]
+		if size > len(body) {
+			// Streamed WAVs leave the data size unset or too large
+			size = len(body)
+		}
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, 0, errors.New("fmt chunk is too short")
+			}
+			format := binary.LittleEndian.Uint16(body[0:2])
+			channels := binary.LittleEndian.Uint16(body[2:4])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if format != 1 || channels != 1 || bits != 16 {
+				return nil, 0, fmt.Errorf("only 16-bit mono PCM is supported, got format %d, %d channels, %d bits",
+					format, channels, bits)
+			}
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+		case "data":
+			pcm = body[:size]
+		}
+
+		// Chunks are padded to an even size
+		size += size % 2
+		if size > len(body) {
+			break
+		}
+		rest = body[size:]
+	}
+
+	if sampleRate == 0 {
+		return nil, 0, errors.New("no fmt chunk")
+	}
+	if pcm == nil {
+		return nil, 0, errors.New("no data chunk")
+	}
+	return decodePCM(pcm), sampleRate, nil
+}
+
+func decodePCM(pcm []byte) []int16 {
+	samples := make([]int16, len(pcm)/2)
+	binary.Read(bytes.NewReader(pcm[:len(samples)*2]), binary.LittleEndian, samples)
+	return samples
+}
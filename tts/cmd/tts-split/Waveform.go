@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+	"tts/src/tts"
+)
+
+// floorDB is the quietest level drawn, anything below is a flat line
+const floorDB = -60.0
+
+var (
+	backgroundColor = color.RGBA{255, 255, 255, 255}
+	silenceColor    = color.RGBA{255, 221, 221, 255}
+	waveColor       = color.RGBA{96, 96, 96, 255}
+	thresholdColor  = color.RGBA{0, 160, 0, 255}
+	boundaryColor   = color.RGBA{0, 64, 255, 255}
+)
+
+// peaks returns the loudest sample of each of width equal slices of samples,
+// in dBFS scaled to 0 (floorDB or quieter) through 1 (full scale)
+func peaks(samples []int16, width int) []float64 {
+	levels := make([]float64, width)
+	for col := range levels {
+		start, end := col*len(samples)/width, (col+1)*len(samples)/width
+		peak := 0.0
+		for _, sample := range samples[start:end] {
+			peak = math.Max(peak, math.Abs(float64(sample)))
+		}
+		levels[col] = level(20 * math.Log10(peak/32768))
+	}
+	return levels
+}
+
+// level scales a dBFS value between floorDB and 0 to between 0 and 1
+func level(db float64) float64 {
+	return math.Min(math.Max((db-floorDB)/-floorDB, 0), 1)
+}
+
+// column is the column of a width wide drawing that sample falls in
+func column(sample, samples, width int) int {
+	if samples == 0 {
+		return 0
+	}
+	return min(sample*width/samples, width-1)
+}
+
+// asciiWaveform draws the peak level of each column in dB with the silence
+// threshold as a dashed line, then marks silences with _ and segment
+// boundaries with [ and ] below it
+func asciiWaveform(samples []int16, analysis tts.SilenceAnalysis, threshDB float64, width, height int) string {
+	levels := peaks(samples, width)
+	threshRow := int(math.Round(level(threshDB) * float64(height)))
+
+	var out strings.Builder
+	for row := height; row >= 1; row-- {
+		for _, l := range levels {
+			switch {
+			case l*float64(height) >= float64(row)-0.5:
+				out.WriteByte('#')
+			case row == threshRow:
+				out.WriteByte('-')
+			default:
+				out.WriteByte(' ')
+			}
+		}
+		out.WriteByte('\n')
+	}
+
+	marks := []byte(strings.Repeat(" ", width))
+	for _, silence := range analysis.Silences {
+		for col := column(silence.Start, len(samples), width); col <= column(max(silence.End-1, 0), len(samples), width); col++ {
+			marks[col] = '_'
+		}
+	}
+	for _, segment := range analysis.Segments {
+		marks[column(segment.Start, len(samples), width)] = '['
+		marks[column(max(segment.End-1, 0), len(samples), width)] = ']'
+	}
+	out.Write(marks)
+	out.WriteByte('\n')
+
+	duration := fmt.Sprintf("%d ms", milliseconds(len(samples), analysis.SampleRate))
+	out.WriteString("0 ms" + strings.Repeat(" ", max(width-len("0 ms")-len(duration), 1)) + duration + "\n")
+	return out.String()
+}
+
+// writeWaveformPNG draws the waveform in dB around a center line, shading
+// silences red, with the threshold in green and segment boundaries in blue
+func writeWaveformPNG(path string, samples []int16, analysis tts.SilenceAnalysis, threshDB float64, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+		for x := max(x0, 0); x < min(x1, width); x++ {
+			for y := max(y0, 0); y < min(y1, height); y++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	fill(0, 0, width, height, backgroundColor)
+
+	for _, silence := range analysis.Silences {
+		fill(column(silence.Start, len(samples), width), 0, column(max(silence.End-1, 0), len(samples), width)+1, height, silenceColor)
+	}
+
+	mid := height / 2
+	for x, l := range peaks(samples, width) {
+		half := int(math.Round(l * float64(mid-1)))
+		fill(x, mid-half, x+1, mid+half+1, waveColor)
+	}
+
+	thresh := int(math.Round(level(threshDB) * float64(mid-1)))
+	fill(0, mid-thresh, width, mid-thresh+1, thresholdColor)
+	fill(0, mid+thresh, width, mid+thresh+1, thresholdColor)
+
+	for _, segment := range analysis.Segments {
+		for _, sample := range []int{segment.Start, max(segment.End-1, 0)} {
+			x := column(sample, len(samples), width)
+			fill(x, 0, x+1, height, boundaryColor)
+		}
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
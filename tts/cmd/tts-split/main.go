@@ -0,0 +1,168 @@
+// Command tts-split shows how the service splits batch audio on silence.
+//
+//	tts-split -in batch.wav
+//	tts-split -in batch.pcm -thresh-db -35 -min-silence-ms 250 -words 12
+//
+// It runs the same splitter as batch synthesis on a 16-bit mono WAV, or raw
+// PCM as the providers return it, and prints the silent regions and segments
+// it detects as JSON. The JSON, each segment as segment-NN.wav and a
+// waveform.png marking silences and boundaries are written to -out, and an
+// ASCII waveform is printed to stderr. The defaults are the service's, read
+// from its config file and environment like the service does.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"tts/src/config"
+	"tts/src/tts"
+)
+
+// serviceRate is the sample rate batch synthesis requests and splits at
+const serviceRate = 24000
+
+// Silence is a detected silent region
+type Silence struct {
+	StartMs    int `json:"start_ms"`
+	EndMs      int `json:"end_ms"`
+	DurationMs int `json:"duration_ms"`
+}
+
+// Segment is one clip the splitter cuts, as it would be stored for a word
+type Segment struct {
+	Index      int    `json:"index"`
+	StartMs    int    `json:"start_ms"`
+	EndMs      int    `json:"end_ms"`
+	DurationMs int    `json:"duration_ms"`
+	File       string `json:"file"`
+}
+
+// Report is the JSON output of a run
+type Report struct {
+	Input      string              `json:"input"`
+	Config     tts.TTSConfig       `json:"config"`
+	SampleRate int                 `json:"sample_rate"`
+	DurationMs int                 `json:"duration_ms"`
+	Words      int                 `json:"words,omitempty"`
+	Silences   []Silence           `json:"silences"`
+	Segments   []Segment           `json:"segments"`
+	Samples    tts.SilenceAnalysis `json:"samples"`
+}
+
+func main() {
+	// The defaults are the deployment's, from the same file and environment as
+	// the service, but splitting needs none of its credentials
+	loaded, err := config.Read()
+	if err != nil {
+		fatal(err)
+	}
+	defaults := loaded.Synthesis
+
+	in := flag.String("in", "", "16-bit mono WAV or raw PCM file to split")
+	out := flag.String("out", "", "directory to write the report, segments and waveform to, defaults to <input>-split")
+	rawRate := flag.Int("raw-rate", serviceRate, "sample rate of input without a WAV header")
+	threshDB := flag.Float64("thresh-db", defaults.SilenceThreshDB, "level in dBFS at or below which audio is silent")
+	minSilence := flag.Int("min-silence-ms", defaults.MinSilenceLen, "shortest silence to split on")
+	keepSilence := flag.Int("keep-silence-ms", defaults.KeepSilence, "silence kept on each side of a segment")
+	seekStep := flag.Int("seek-step", defaults.SeekStep, "samples between level checks")
+	words := flag.Int("words", 0, "number of words in the batch, to check the segment count against")
+	width := flag.Int("width", 100, "columns of the ASCII waveform")
+	flag.Parse()
+
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "-in is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *threshDB >= 0 || *minSilence <= 0 || *keepSilence < 0 || *seekStep <= 0 || *rawRate <= 0 || *width < 20 {
+		fmt.Fprintln(os.Stderr, "-thresh-db must be negative, -min-silence-ms, -seek-step and -raw-rate positive, "+
+			"-keep-silence-ms not negative and -width at least 20")
+		os.Exit(2)
+	}
+	if *out == "" {
+		*out = strings.TrimSuffix(*in, filepath.Ext(*in)) + "-split"
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		fatal(err)
+	}
+	samples, sampleRate, err := decodeWAV(data, *rawRate)
+	if err != nil {
+		fatal(fmt.Errorf("failed to read %s: %w", *in, err))
+	}
+	if sampleRate != serviceRate {
+		fmt.Fprintf(os.Stderr, "warning: the service splits %d Hz audio, this is %d Hz\n", serviceRate, sampleRate)
+	}
+
+	ttsConfig := tts.TTSConfig{
+		BreakDurationMs: defaults.BreakDurationMs,
+		SilenceThreshDB: *threshDB,
+		MinSilenceLen:   *minSilence,
+		KeepSilence:     *keepSilence,
+		SeekStep:        *seekStep,
+	}
+	analysis := tts.AnalyzeSilence(ttsConfig, samples, sampleRate)
+
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		fatal(fmt.Errorf("failed to create %s: %w", *out, err))
+	}
+
+	report := Report{
+		Input:      *in,
+		Config:     ttsConfig,
+		SampleRate: sampleRate,
+		DurationMs: milliseconds(len(samples), sampleRate),
+		Words:      *words,
+		Silences:   []Silence{},
+		Samples:    analysis,
+	}
+	for _, silence := range analysis.Silences {
+		start, end := milliseconds(silence.Start, sampleRate), milliseconds(silence.End, sampleRate)
+		report.Silences = append(report.Silences, Silence{StartMs: start, EndMs: end, DurationMs: end - start})
+	}
+	for i, segment := range analysis.Segments {
+		name := fmt.Sprintf("segment-%02d.wav", i)
+		if err := os.WriteFile(filepath.Join(*out, name), tts.EncodeWAV(samples[segment.Start:segment.End], sampleRate), 0o644); err != nil {
+			fatal(fmt.Errorf("failed to write %s: %w", name, err))
+		}
+		start, end := milliseconds(segment.Start, sampleRate), milliseconds(segment.End, sampleRate)
+		report.Segments = append(report.Segments, Segment{Index: i, StartMs: start, EndMs: end, DurationMs: end - start, File: name})
+	}
+
+	if err := writeWaveformPNG(filepath.Join(*out, "waveform.png"), samples, analysis, *threshDB, 1200, 240); err != nil {
+		fatal(err)
+	}
+
+	encoded, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(*out, "analysis.json"), append(encoded, '\n'), 0o644); err != nil {
+		fatal(fmt.Errorf("failed to write analysis.json: %w", err))
+	}
+	fmt.Println(string(encoded))
+
+	fmt.Fprint(os.Stderr, asciiWaveform(samples, analysis, *threshDB, *width, 8))
+	fmt.Fprintf(os.Stderr, "%d silences, %d segments, written to %s\n", len(analysis.Silences), len(analysis.Segments), *out)
+	if *words > 0 && *words != len(analysis.Segments) {
+		fmt.Fprintf(os.Stderr, "expected %d segments for %d words, got %d\n", *words, *words, len(analysis.Segments))
+		os.Exit(1)
+	}
+}
+
+func milliseconds(samples, sampleRate int) int {
+	if sampleRate == 0 {
+		return 0
+	}
+	return samples * 1000 / sampleRate
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
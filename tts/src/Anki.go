@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"tts/src/anki"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/storage"
+	"tts/src/synthesis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// soundTagsFile lists the tag of each bundled clip for the caller building
+// notes. Anki does not import it, so the bundle can be merged as it is.
+const soundTagsFile = "sound_tags.json"
+
+// bundleParallelism is how many clips of a bundle are looked up at once
+const bundleParallelism = 16
+
+type MediaBundleRequest struct {
+	Items []MediaBundleItem `json:"items" binding:"required,min=1,max=5000,dive"`
+	// FirstIndex numbers the files after the media already in the package
+	// the bundle is merged into
+	FirstIndex int `json:"first_index" binding:"gte=0"`
+}
+
+type MediaBundleItem struct {
+	Id   int    `json:"context_id" binding:"gte=0"`
+	Kind string `json:"kind" binding:"required,oneof=word sentence"`
+	// Filename the clip gets in the collection, tts_<kind>_<id>.wav by default
+	Filename string `json:"filename" binding:"omitempty,ankifile"`
+}
+
+// SoundTag is the tag that plays one bundled clip
+type SoundTag struct {
+	Id       int    `json:"context_id"`
+	Kind     string `json:"kind"`
+	Filename string `json:"filename"`
+	Tag      string `json:"tag"`
+}
+
+// handleMediaBundleRequest streams stored clips as a ZIP laid out like the
+// media of an .apkg: numbered files, the media map, and the suggested
+// [sound:] tag of each clip in sound_tags.json
+func handleMediaBundleRequest(c *gin.Context) {
+	var req MediaBundleRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	tags := make([]SoundTag, len(req.Items))
+	seen := make(map[string]bool, len(req.Items))
+	for i, item := range req.Items {
+		filename := item.Filename
+		if filename == "" {
+			filename = fmt.Sprintf("tts_%s_%d.wav", item.Kind, item.Id)
+		}
+		// Collections on Windows and macOS do not tell names apart by case
+		if seen[strings.ToLower(filename)] {
+			apierror.Abort(c, http.StatusBadRequest, apierror.ValidationFailed,
+				fmt.Sprintf("filename %q is used more than once, ignoring case", filename))
+			return
+		}
+		seen[strings.ToLower(filename)] = true
+		tags[i] = SoundTag{Id: item.Id, Kind: item.Kind, Filename: filename, Tag: anki.SoundTag(filename)}
+	}
+
+	// Missing clips are reported before anything is streamed, a failure
+	// half way through can only be signalled by a truncated archive
+	missing, err := missingClips(ctx, tags)
+	if err != nil {
+		metrics.Error(metrics.ErrorDownload)
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to retrieve audio: %v", err))
+		return
+	}
+	if len(missing) > 0 {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound,
+			"No audio is stored for "+strings.Join(missing, ", "))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", "attachment; filename=media.zip")
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	if err := writeMediaBundle(ctx, c.Writer, req.FirstIndex, tags); err != nil {
+		metrics.Error(metrics.ErrorDownload)
+		logging.FromContext(ctx).Error("media bundle interrupted", "error", err)
+	}
+}
+
+// missingClips names the clips of tags that are not stored
+func missingClips(ctx context.Context, tags []SoundTag) ([]string, error) {
+	var missing []string
+	for start := 0; start < len(tags); start += bundleParallelism {
+		window := tags[start:min(start+bundleParallelism, len(tags))]
+		for i, clip := range openClips(ctx, window) {
+			switch {
+			case storage.IsNotFound(clip.err):
+				missing = append(missing, fmt.Sprintf("%s %d", window[i].Kind, window[i].Id))
+			case clip.err != nil:
+				return nil, clip.err
+			default:
+				clip.audio.Close()
+			}
+		}
+	}
+	return missing, nil
+}
+
+func writeMediaBundle(ctx context.Context, w http.ResponseWriter, first int, tags []SoundTag) error {
+	bundle := anki.NewMediaWriter(w, first)
+	for start := 0; start < len(tags); start += bundleParallelism {
+		window := tags[start:min(start+bundleParallelism, len(tags))]
+		if err := addClips(bundle, window, openClips(ctx, window)); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode sound tags: %w", err)
+	}
+	if err := bundle.WriteFile(soundTagsFile, data); err != nil {
+		return err
+	}
+	return bundle.Close()
+}
+
+// addClips adds opened clips to bundle in order, closing every one of them
+func addClips(bundle *anki.MediaWriter, tags []SoundTag, clips []openedClip) error {
+	defer func() {
+		for _, clip := range clips {
+			if clip.audio != nil {
+				clip.audio.Close()
+			}
+		}
+	}()
+
+	for i, clip := range clips {
+		if clip.err != nil {
+			return fmt.Errorf("failed to open %s %d: %w", tags[i].Kind, tags[i].Id, clip.err)
+		}
+		if _, err := bundle.Add(tags[i].Filename, clip.audio); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openedClip is a clip opened for a bundle, or why it could not be
+type openedClip struct {
+	audio storage.AudioBlob
+	err   error
+}
+
+// openClips opens the clip of each tag at once. Opening only looks the clip
+// up, its audio is downloaded as it is read.
+func openClips(ctx context.Context, tags []SoundTag) []openedClip {
+	clips := make([]openedClip, len(tags))
+	var wg sync.WaitGroup
+	for i, tag := range tags {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path := synthesis.AudioPath(ctx, strconv.Itoa(tag.Id), tag.Kind == "sentence")
+			clips[i].audio, clips[i].err = blobDB.OpenTTSAudio(ctx, path)
+		}()
+	}
+	wg.Wait()
+	return clips
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"tts/src/accounting"
+	"tts/src/apierror"
+	"tts/src/auth"
+	"tts/src/logging"
+	"tts/src/storage"
+	"tts/src/synthesis"
+	"tts/src/tts"
+	"tts/src/ttspb"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// audioChunkSize is how much audio each streamed message carries
+const audioChunkSize = 32 * 1024
+
+// maxBatchGetAudio is the most clips one BatchGetAudio call may ask for, as
+// each is read into memory before it is sent
+const maxBatchGetAudio = 100
+
+// grpcServer implements ttspb.TTSServiceServer on top of the same engines,
+// worker queue and storage as the REST handlers
+type grpcServer struct {
+	ttspb.UnimplementedTTSServiceServer
+	authenticator *auth.Authenticator
+}
+
+func newGRPCServer(authenticator *auth.Authenticator) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authenticator.UnaryInterceptor()),
+		grpc.ChainStreamInterceptor(authenticator.StreamInterceptor()),
+	)
+	ttspb.RegisterTTSServiceServer(server, &grpcServer{authenticator: authenticator})
+	return server
+}
+
+func (s *grpcServer) Process(ctx context.Context, in *ttspb.ProcessRequest) (*ttspb.Job, error) {
+	sentence, err := sentenceKind(in.GetKind())
+	if err != nil {
+		return nil, err
+	}
+
+	req := ProcessRequest{Engine: in.GetEngine(), Gender: in.GetGender()}
+	for _, word := range in.GetWords() {
+		req.Words = append(req.Words, tts.Word{
+			Id:            int(word.GetContextId()),
+			Text:          word.GetText(),
+			Pronunciation: word.GetPronunciation(),
+		})
+	}
+	if err := validateRPC(&req); err != nil {
+		return nil, err
+	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
+
+	engine, err := rpcEngine(req.Engine)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := s.acquireJob(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	job, err := processWords(ctx, engine, req.Words, req.Gender, sentence)
+	if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrPoolClosed) || errors.Is(err, accounting.ErrBudgetExceeded) {
+		return nil, rpcSynthesisError(err)
+	}
+	// Other failures are reported on the job, like a REST client polling it would see
+	return jobMessage(job), nil
+}
+
+func (s *grpcServer) Synthesize(in *ttspb.SynthesizeRequest, stream grpc.ServerStreamingServer[ttspb.AudioChunk]) error {
+	req := PreviewRequest{
+		Engine:        in.GetEngine(),
+		Gender:        in.GetGender(),
+		Voice:         in.GetVoice(),
+		Text:          in.GetText(),
+		Pronunciation: in.GetPronunciation(),
+	}
+	if in.RatePercent != nil {
+		rate := in.GetRatePercent()
+		req.RatePercent = &rate
+	}
+	if err := validateRPC(&req); err != nil {
+		return err
+	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
+
+	engine, err := rpcEngine(req.Engine)
+	if err != nil {
+		return err
+	}
+	if err := engine.CheckVoice(req.Voice); err != nil {
+		return apierror.Status(codes.InvalidArgument, apierror.UnknownVoice, err.Error())
+	}
+
+	release, err := s.acquireJob(stream.Context())
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	word := tts.Word{Text: req.Text, Pronunciation: req.Pronunciation}
+	options := tts.SynthesisOptions{RatePercent: req.RatePercent}
+
+	// The worker is held until the audio has been streamed, so a slow reader
+	// cannot leave provider connections open beyond the pool's size
+	var streamErr error
+	done, err := workers.Enqueue(stream.Context(), func(ctx context.Context) error {
+		audio, err := engine.PreviewStream(ctx, word, req.Voice, req.Gender, options)
+		if err != nil {
+			return err
+		}
+		defer audio.Close()
+		streamErr = sendAudio(stream, audio)
+		return nil
+	})
+	if err != nil {
+		return rpcSynthesisError(err)
+	}
+	// Waited for even once the client has gone, the worker still uses stream
+	if err := <-done; err != nil {
+		return rpcSynthesisError(err)
+	}
+	return streamErr
+}
+
+// sendAudio streams audio to the client in audioChunkSize messages
+func sendAudio(stream grpc.ServerStreamingServer[ttspb.AudioChunk], audio io.Reader) error {
+	buf := make([]byte, audioChunkSize)
+	for {
+		n, err := io.ReadFull(audio, buf)
+		if n > 0 {
+			if sendErr := stream.Send(&ttspb.AudioChunk{Data: buf[:n]}); sendErr != nil {
+				return sendErr
+			}
+		}
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			return nil
+		case err != nil:
+			logging.FromContext(stream.Context()).Error("audio stream interrupted", "error", err)
+			return apierror.Status(codes.Internal, apierror.SynthesisFailed, err.Error())
+		}
+	}
+}
+
+func (s *grpcServer) BatchGetAudio(in *ttspb.BatchGetAudioRequest, stream grpc.ServerStreamingServer[ttspb.AudioClip]) error {
+	sentence, err := sentenceKind(in.GetKind())
+	if err != nil {
+		return err
+	}
+	if len(in.GetContextIds()) == 0 {
+		return apierror.Status(codes.InvalidArgument, apierror.ValidationFailed, "context_ids must not be empty")
+	}
+	if len(in.GetContextIds()) > maxBatchGetAudio {
+		return apierror.Status(codes.InvalidArgument, apierror.ValidationFailed,
+			fmt.Sprintf("context_ids must not have more than %d ids", maxBatchGetAudio))
+	}
+
+	ctx := stream.Context()
+	for _, id := range in.GetContextIds() {
+		clip := &ttspb.AudioClip{ContextId: id}
+
+		audio, err := blobDB.OpenTTSAudio(ctx, synthesis.AudioPath(ctx, strconv.FormatInt(id, 10), sentence))
+		switch {
+		case err == nil:
+			clip.Found = true
+			clip.Etag = audio.Properties().ETag
+			clip.Data, err = io.ReadAll(audio)
+			audio.Close()
+			if err != nil {
+				return apierror.Status(codes.Internal, apierror.StorageFailed, fmt.Sprintf("failed to read clip %d: %v", id, err))
+			}
+		case !storage.IsNotFound(err):
+			return apierror.Status(codes.Internal, apierror.StorageFailed, fmt.Sprintf("failed to open clip %d: %v", id, err))
+		}
+
+		if err := stream.Send(clip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, in *ttspb.GetJobRequest) (*ttspb.Job, error) {
+	job, ok := visibleJob(ctx, in.GetId())
+	if !ok {
+		return nil, apierror.Status(codes.NotFound, apierror.NotFound, "job not found")
+	}
+	return jobMessage(job), nil
+}
+
+func (s *grpcServer) ListVoices(ctx context.Context, in *ttspb.ListVoicesRequest) (*ttspb.ListVoicesResponse, error) {
+	names := cfg.EnabledProviders()
+	if in.GetEngine() != "" {
+		if _, err := rpcEngine(in.GetEngine()); err != nil {
+			return nil, err
+		}
+		names = []string{strings.ToLower(in.GetEngine())}
+	}
+
+	response := &ttspb.ListVoicesResponse{}
+	for _, name := range names {
+		voices := engines[name].Voices()
+		genders := make([]string, 0, len(voices))
+		for gender := range voices {
+			genders = append(genders, gender)
+		}
+		sort.Strings(genders)
+
+		for _, gender := range genders {
+			response.Voices = append(response.Voices, &ttspb.Voice{Engine: name, Gender: gender, Name: voices[gender]})
+		}
+	}
+	return response, nil
+}
+
+// acquireJob applies the caller's concurrent job limit, like LimitJobs does for REST
+func (s *grpcServer) acquireJob(ctx context.Context) (func(), error) {
+	client := auth.FromContext(ctx)
+	if !s.authenticator.AcquireJob(client) {
+		return nil, apierror.Status(codes.ResourceExhausted, apierror.TooManyJobs,
+			fmt.Sprintf("too many concurrent jobs (limit %d)", client.MaxConcurrentJobs))
+	}
+	return func() { s.authenticator.ReleaseJob(client) }, nil
+}
+
+// validateRPC checks a converted request against the same binding rules as REST
+func validateRPC(req any) error {
+	err := binding.Validator.ValidateStruct(req)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return apierror.Status(codes.InvalidArgument, apierror.ValidationFailed, err.Error())
+	}
+	var fields []string
+	for _, fieldErr := range validationErrs {
+		_, field, _ := strings.Cut(fieldErr.Namespace(), ".")
+		fields = append(fields, fmt.Sprintf("%s (%s)", field, fieldErr.Tag()))
+	}
+	return apierror.Status(codes.InvalidArgument, apierror.ValidationFailed, "invalid fields: "+strings.Join(fields, ", "))
+}
+
+func rpcEngine(name string) (*synthesis.Engine, error) {
+	engine, err := engineFor(name)
+	if err != nil {
+		return nil, apierror.Status(codes.InvalidArgument, apierror.EngineDisabled, err.Error())
+	}
+	return engine, nil
+}
+
+// rpcSynthesisError is writeSynthesisError for gRPC
+func rpcSynthesisError(err error) error {
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		return apierror.Status(codes.Unavailable, apierror.QueueFull, err.Error())
+	case errors.Is(err, synthesis.ErrUnknownVoice):
+		return apierror.Status(codes.InvalidArgument, apierror.UnknownVoice, err.Error())
+	case errors.Is(err, ErrPoolClosed):
+		return apierror.Status(codes.Unavailable, apierror.ShuttingDown, err.Error())
+	case errors.Is(err, accounting.ErrBudgetExceeded):
+		return apierror.Status(codes.ResourceExhausted, apierror.BudgetExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return apierror.Status(codes.Canceled, apierror.SynthesisFailed, err.Error())
+	default:
+		return apierror.Status(codes.Internal, apierror.SynthesisFailed, err.Error())
+	}
+}
+
+func sentenceKind(kind ttspb.ClipKind) (bool, error) {
+	switch kind {
+	case ttspb.ClipKind_CLIP_KIND_WORD:
+		return false, nil
+	case ttspb.ClipKind_CLIP_KIND_SENTENCE:
+		return true, nil
+	default:
+		return false, apierror.Status(codes.InvalidArgument, apierror.ValidationFailed, "kind must be word or sentence")
+	}
+}
+
+// jobKinds maps the kind of a job to its message, any other kind being unspecified
+var jobKinds = map[string]ttspb.ClipKind{
+	synthesis.ClipKind(false): ttspb.ClipKind_CLIP_KIND_WORD,
+	synthesis.ClipKind(true):  ttspb.ClipKind_CLIP_KIND_SENTENCE,
+	JobReconcile:              ttspb.ClipKind_CLIP_KIND_RECONCILE,
+	JobRetention:              ttspb.ClipKind_CLIP_KIND_RETENTION,
+}
+
+func jobMessage(job Job) *ttspb.Job {
+	message := &ttspb.Job{
+		Id:        job.ID,
+		Kind:      jobKinds[job.Kind],
+		Engine:    job.Engine,
+		Status:    job.Status,
+		Words:     int32(job.Words),
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt.UnixMilli(),
+	}
+	if job.FinishedAt != nil {
+		message.FinishedAt = job.FinishedAt.UnixMilli()
+	}
+	for _, result := range job.Results {
+		message.Results = append(message.Results, &ttspb.WordResult{
+			ContextId: int64(result.Id),
+			Stage:     result.Status,
+			Url:       result.URL,
+			Error:     result.Error,
+		})
+	}
+	return message
+}
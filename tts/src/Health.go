@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type dependencyStatus struct {
+	Status    string `json:"status"`
+	Required  bool   `json:"required"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+	Details   gin.H  `json:"details,omitempty"`
+}
+
+type dependencyCheck struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) (gin.H, error)
+}
+
+// handleHealthRequest reports the process is alive, without touching dependencies
+func handleHealthRequest(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyRequest checks every dependency concurrently and answers 503 when a
+// required one is unavailable
+func handleReadyRequest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := []dependencyCheck{
+		{name: "storage", required: true, check: checkStorage},
+		{name: "workers", required: true, check: checkWorkers},
+	}
+	if manifest != nil {
+		checks = append(checks, dependencyCheck{name: "manifest", required: true, check: checkManifest})
+	}
+	for _, provider := range cfg.EnabledProviders() {
+		checks = append(checks, dependencyCheck{
+			name:     "provider_" + provider,
+			required: provider == cfg.DefaultProvider,
+			check:    checkProvider(provider),
+		})
+	}
+
+	results := make(map[string]dependencyStatus, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range checks {
+		wg.Add(1)
+		go func(dep dependencyCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			details, err := dep.check(ctx)
+			result := dependencyStatus{
+				Status:    "ok",
+				Required:  dep.required,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Details:   details,
+			}
+			if err != nil {
+				result.Status = "unavailable"
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			results[dep.name] = result
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	for _, result := range results {
+		if result.Status == "ok" {
+			continue
+		}
+		if result.Required {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			break
+		}
+		status = "degraded"
+	}
+
+	c.JSON(code, gin.H{
+		"status": status,
+		"checks": results,
+	})
+}
+
+func checkStorage(ctx context.Context) (gin.H, error) {
+	return gin.H{"backend": cfg.Storage.Backend}, blobDB.Ping(ctx)
+}
+
+// providerCheckTTL is how long a provider credentials check is reused. The
+// ready route is unauthenticated, so without it every probe would call the
+// provider's token endpoint.
+const providerCheckTTL = 30 * time.Second
+
+// providerCheck is the last credentials check of a provider
+type providerCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+var (
+	providerChecksMu sync.Mutex
+	providerChecks   = make(map[string]*providerCheck)
+)
+
+// checkProvider checks the credentials of provider at most once per
+// providerCheckTTL, callers in between sharing the last result
+func checkProvider(provider string) func(ctx context.Context) (gin.H, error) {
+	providerChecksMu.Lock()
+	last, ok := providerChecks[provider]
+	if !ok {
+		last = &providerCheck{}
+		providerChecks[provider] = last
+	}
+	providerChecksMu.Unlock()
+
+	return func(ctx context.Context) (gin.H, error) {
+		last.mu.Lock()
+		defer last.mu.Unlock()
+		if time.Since(last.checkedAt) >= providerCheckTTL {
+			// Not bound to the request, so a caller hanging up is not cached as a failure
+			checkCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			last.err = engines[provider].CheckCredentials(checkCtx)
+			last.checkedAt = time.Now()
+		}
+		return gin.H{"checked_at": last.checkedAt.UTC()}, last.err
+	}
+}
+
+func checkWorkers(ctx context.Context) (gin.H, error) {
+	details := gin.H{
+		"workers":  workers.Workers(),
+		"active":   workers.Active(),
+		"queued":   workers.Queued(),
+		"capacity": workers.Capacity(),
+	}
+	if workers.Capacity() > 0 && workers.Queued() >= workers.Capacity() {
+		return details, ErrQueueFull
+	}
+	return details, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RollbackRequest struct {
+	Version string `json:"version" binding:"required"`
+}
+
+type HistoryResponse struct {
+	Versions []storage.ClipVersion `json:"versions"`
+}
+
+type RollbackResponse struct {
+	URL      string                `json:"url"`
+	Metadata *storage.ClipMetadata `json:"metadata"`
+}
+
+// handleHistoryRequest lists the current clip for a word or sentence and its
+// earlier versions, newest first, with the metadata recorded for each
+func handleHistoryRequest(c *gin.Context) {
+	path, ok := clipPathParam(c)
+	if !ok {
+		return
+	}
+
+	history, err := clips.History(c.Request.Context(), path)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio file not found")
+			return
+		}
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to list audio history: %v", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, HistoryResponse{Versions: history})
+}
+
+// handleRollbackRequest makes an earlier version of a clip current again
+func handleRollbackRequest(c *gin.Context) {
+	path, ok := clipPathParam(c)
+	if !ok {
+		return
+	}
+
+	var req RollbackRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	url, metadata, err := clips.Rollback(c.Request.Context(), path, req.Version)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio version not found")
+			return
+		}
+		metrics.Error(metrics.ErrorUpload)
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to roll back audio: %v", err))
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).Info("rolled back audio", "path", path, "version", req.Version)
+	c.JSON(http.StatusOK, RollbackResponse{URL: clipURL(c.Request.Context(), path, url), Metadata: metadata})
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"tts/src/accounting"
+	"tts/src/apierror"
+	"tts/src/auth"
+	"tts/src/logging"
+	"tts/src/namespace"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// jobHistory is how many finished and running jobs are kept for status lookups
+const jobHistory = 1000
+
+// Job tracks one batch synthesis request
+type Job struct {
+	ID        string       `json:"id"`
+	Namespace string       `json:"namespace,omitempty"`
+	Kind      string       `json:"kind"`
+	Engine    string       `json:"engine"`
+	Caller    string       `json:"caller"`
+	Status    string       `json:"status"`
+	Words     int          `json:"words"`
+	Error     string       `json:"error,omitempty"`
+	Results   []WordResult `json:"results,omitempty"`
+	// Report is what a sweep job found, see startSweep
+	Report     any        `json:"report,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// WordResult is the outcome of one word once its job has finished
+type WordResult struct {
+	Id     int    `json:"context_id"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// JobStore keeps the most recent jobs in memory, dropping the oldest beyond its limit
+type JobStore struct {
+	mu    sync.Mutex
+	jobs  map[string]*Job
+	feeds map[string]*progressFeed
+	order []string
+	limit int
+}
+
+func NewJobStore(limit int) *JobStore {
+	return &JobStore{jobs: make(map[string]*Job), feeds: make(map[string]*progressFeed), limit: limit}
+}
+
+// Create records a new queued job and returns a copy of it
+func (s *JobStore) Create(ns, kind, engine, caller string, words int) Job {
+	job := &Job{
+		ID:        logging.NewRequestID(),
+		Namespace: ns,
+		Kind:      kind,
+		Engine:    engine,
+		Caller:    caller,
+		Status:    JobQueued,
+		Words:     words,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = job
+	s.feeds[job.ID] = newProgressFeed(words)
+	s.order = append(s.order, job.ID)
+	for len(s.order) > s.limit {
+		s.dropFeed(s.order[0])
+		delete(s.jobs, s.order[0])
+		s.order = s.order[1:]
+	}
+	return *job
+}
+
+// Update applies update to the stored job, if it is still kept
+func (s *JobStore) Update(id string, update func(*Job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		update(job)
+	}
+}
+
+func (s *JobStore) Get(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	copied := *job
+	copied.Results = append([]WordResult(nil), job.Results...)
+	return copied, true
+}
+
+// processWords runs a batch through the worker queue as a tracked job and
+// returns the job as it finished
+func processWords(ctx context.Context, engine *synthesis.Engine, words []tts.Word, gender string, sentence bool) (Job, error) {
+	job, wait, err := startBatchJob(ctx, engine, words, gender, sentence)
+	if err != nil {
+		return job, err
+	}
+	return wait()
+}
+
+// jobRun synthesizes and stores the words of a job, reporting their progress.
+// It returns the results of the words it got to, in order.
+type jobRun func(ctx context.Context, progress synthesis.ProgressFunc) ([]WordResult, error)
+
+// startBatchJob starts a job synthesizing words in a single provider request,
+// see Engine.BatchProcessWords
+func startBatchJob(ctx context.Context, engine *synthesis.Engine, words []tts.Word, gender string, sentence bool) (Job, func() (Job, error), error) {
+	return startJob(ctx, synthesis.ClipKind(sentence), engine.Name(), words, func(ctx context.Context, progress synthesis.ProgressFunc) ([]WordResult, error) {
+		urls, err := engine.BatchProcessWords(ctx, words, gender, sentence, progress)
+		results := make([]WordResult, len(urls))
+		for i, url := range urls {
+			results[i] = WordResult{Id: words[i].Id, Status: synthesis.StageUploaded, URL: url}
+		}
+		return results, err
+	})
+}
+
+// startJob records a job and queues run without waiting for it. wait blocks
+// until run has finished and returns the finished job. A full queue fails the
+// job straight away. The caller's job slot is held until run has finished,
+// even when the request starting the job has already been answered.
+func startJob(ctx context.Context, kind, engine string, words []tts.Word, run jobRun) (Job, func() (Job, error), error) {
+	release := auth.KeepJob(ctx)
+
+	job := jobs.Create(namespace.FromContext(ctx), kind, engine, accounting.CallerFromContext(ctx), len(words))
+	ctx = logging.With(ctx, "job", job.ID)
+	recordJob(ctx, job, words)
+
+	progress := func(p synthesis.WordProgress) {
+		jobs.Publish(job.ID, ProgressEvent{Index: p.Index, Id: words[p.Index].Id, Stage: p.Stage, URL: p.URL, Error: p.Error})
+	}
+
+	for i := range words {
+		progress(synthesis.WordProgress{Index: i, Stage: synthesis.StageQueued})
+	}
+
+	var results []WordResult
+	done, err := workers.Enqueue(ctx, func(ctx context.Context) error {
+		jobs.Update(job.ID, func(j *Job) { j.Status = JobRunning })
+		var err error
+		results, err = run(ctx, progress)
+		return err
+	})
+	if err != nil {
+		release()
+		return finishJob(ctx, job.ID, words, nil, err), nil, err
+	}
+
+	wait := func() (Job, error) {
+		select {
+		case err = <-done:
+			release()
+		case <-ctx.Done():
+			go func() {
+				<-done
+				release()
+			}()
+			// The job may still be running, so its results cannot be read
+			return finishJob(ctx, job.ID, words, nil, ctx.Err()), ctx.Err()
+		}
+		return finishJob(ctx, job.ID, words, results, err), err
+	}
+	return job, wait, nil
+}
+
+// Kinds of sweep jobs, which check stored audio rather than synthesize words
+const (
+	JobReconcile = "reconcile"
+	JobRetention = "retention"
+)
+
+// sweepRun checks or cleans up stored audio and returns a report of what it did
+type sweepRun func(ctx context.Context) (any, error)
+
+// startSweep records a job of kind and runs run in the background, outside the
+// synthesis workers, for work on stored audio that takes too long to wait for
+// in a request. Its report is kept on the job once it has finished.
+func startSweep(ctx context.Context, kind, engine string, run sweepRun) Job {
+	release := auth.KeepJob(ctx)
+	ctx = context.WithoutCancel(ctx)
+
+	job := jobs.Create(namespace.FromContext(ctx), kind, engine, accounting.CallerFromContext(ctx), 0)
+	ctx = logging.With(ctx, "job", job.ID)
+	jobs.Update(job.ID, func(j *Job) { j.Status = JobRunning })
+	recordJob(ctx, job, nil)
+
+	go func() {
+		defer release()
+		report, err := run(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("sweep failed", "kind", kind, "error", err)
+		}
+
+		jobs.Update(job.ID, func(j *Job) {
+			finished := time.Now().UTC()
+			j.FinishedAt = &finished
+			j.Report = report
+			j.Status = JobSucceeded
+			if err != nil {
+				j.Status = JobFailed
+				j.Error = err.Error()
+			}
+		})
+		jobs.closeFeed(job.ID)
+
+		finished, _ := jobs.Get(job.ID)
+		recordJob(ctx, finished, nil)
+	}()
+	return job
+}
+
+// finishJob records the outcome of a job and of each of its words. Words the
+// job did not get to failed, with the job's error if there was one.
+func finishJob(ctx context.Context, id string, words []tts.Word, done []WordResult, err error) Job {
+	results := make([]WordResult, len(words))
+	for i, word := range words {
+		switch {
+		case i < len(done) && done[i].Status != "":
+			results[i] = done[i]
+		case err != nil:
+			results[i] = WordResult{Id: word.Id, Status: synthesis.StageFailed, Error: err.Error()}
+		default:
+			results[i] = WordResult{Id: word.Id, Status: synthesis.StageFailed, Error: synthesis.ErrNoSegment.Error()}
+		}
+	}
+
+	jobs.Update(id, func(j *Job) {
+		finished := time.Now().UTC()
+		j.FinishedAt = &finished
+		j.Results = results
+		j.Status = JobSucceeded
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = err.Error()
+		}
+	})
+
+	// Words whose failure the engine did not report, such as all of them when
+	// synthesis failed, fail now so every subscriber sees each word finish
+	for i, result := range results {
+		if result.Status == synthesis.StageFailed {
+			jobs.Publish(id, ProgressEvent{Index: i, Id: result.Id, Stage: synthesis.StageFailed, Error: result.Error})
+		}
+	}
+	jobs.closeFeed(id)
+
+	job, _ := jobs.Get(id)
+	// A job cancelled with its request is still recorded
+	recordJob(context.WithoutCancel(ctx), job, words)
+	return job
+}
+
+// visibleJob returns a job if the caller may see it. Only admins see other clients' jobs.
+func visibleJob(ctx context.Context, id string) (Job, bool) {
+	job, ok := jobs.Get(id)
+	if !ok {
+		return Job{}, false
+	}
+	if client := auth.FromContext(ctx); !client.Admin && client.Name != job.Caller {
+		return Job{}, false
+	}
+	return job, true
+}
+
+func handleJobRequest(c *gin.Context) {
+	job, ok := visibleJob(c.Request.Context(), c.Param("id"))
+	if !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Job not found")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
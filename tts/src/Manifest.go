@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/namespace"
+	"tts/src/storage"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MissingAudioRequest struct {
+	IDs []int `json:"context_ids" binding:"required,min=1,max=10000,dive,gte=0"`
+}
+
+type MissingAudioResponse struct {
+	Missing []int `json:"missing"`
+}
+
+// recordJob keeps a job and, once it finished, the outcome and text of each
+// word in the manifest. Failures are only logged so jobs never fail on bookkeeping.
+func recordJob(ctx context.Context, job Job, jobWords []tts.Word) {
+	if manifest == nil {
+		return
+	}
+	logger := logging.FromContext(ctx)
+
+	err := manifest.SaveJob(ctx, storage.JobRecord{
+		ID:         job.ID,
+		Namespace:  job.Namespace,
+		Kind:       job.Kind,
+		Engine:     job.Engine,
+		Caller:     job.Caller,
+		Status:     job.Status,
+		Words:      job.Words,
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		FinishedAt: job.FinishedAt,
+	})
+	if err != nil {
+		logger.Warn("manifest is out of date", "error", err)
+		return
+	}
+	if len(job.Results) == 0 {
+		return
+	}
+
+	words := make([]storage.WordRecord, len(job.Results))
+	for i, result := range job.Results {
+		words[i] = storage.WordRecord{
+			Index:         i,
+			ContextID:     result.Id,
+			Text:          jobWords[i].Text,
+			Pronunciation: jobWords[i].Pronunciation,
+			Status:        result.Status,
+			URL:           unsignedURL(result.URL),
+			Error:         result.Error,
+		}
+	}
+	if err := manifest.SaveJobWords(ctx, job.ID, words); err != nil {
+		logger.Warn("manifest is out of date", "error", err)
+	}
+}
+
+// handleMissingAudioRequest answers which of the given words or sentences have
+// no stored clip, from the manifest alone
+func handleMissingAudioRequest(c *gin.Context) {
+	if manifest == nil {
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.ManifestDisabled, "No manifest database is configured")
+		return
+	}
+
+	var req MissingAudioRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	missing, err := manifest.MissingAudio(ctx, namespace.FromContext(ctx), synthesis.ClipKind(isSentenceRoute(c)), req.IDs)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to look up audio: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, MissingAudioResponse{Missing: missing})
+}
+
+func checkManifest(ctx context.Context) (gin.H, error) {
+	return gin.H{"driver": manifest.Driver()}, manifest.Ping(ctx)
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"tts/src/apierror"
+	"tts/src/namespace"
+	"tts/src/openapi"
+	"tts/src/retention"
+	"tts/src/signing"
+	"tts/src/tts"
+	"tts/src/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+type readyResponse struct {
+	Status string                      `json:"status"`
+	Checks map[string]dependencyStatus `json:"checks"`
+}
+
+// openAPIDocument describes every route registered in main. Request and response
+// schemas come from the handler types, including their binding rules.
+func openAPIDocument() *openapi.Document {
+	doc := openapi.New("MandarinAnkiGenerator TTS", version)
+	doc.RegisterPattern("pinyin", tts.PronunciationPattern)
+	doc.RegisterPattern("ankifile", `^[^/\\:*?"<>|\x00-\x1f]{1,120}$`)
+	doc.Components.SecuritySchemes["apiKey"] = openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"}
+	doc.Components.SecuritySchemes["bearer"] = openapi.SecurityScheme{Type: "http", Scheme: "bearer"}
+	security := []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+
+	errorResponse := func(description string) openapi.Response {
+		return openapi.Response{Description: description, Content: doc.JSON(apierror.ErrorResponse{})}
+	}
+	// Every authenticated route can be refused before its handler runs
+	namespaceParam := openapi.HeaderParam(namespace.Header,
+		"Namespace to work in, defaulting to the first one of the API key",
+		&openapi.Schema{Type: "string", Pattern: "^[a-z0-9][a-z0-9_-]{0,62}$"})
+	withAuthErrors := func(op openapi.Operation) openapi.Operation {
+		op.Security = security
+		op.Parameters = append(op.Parameters, namespaceParam)
+		op.Responses["401"] = errorResponse("Missing or invalid API key")
+		if _, ok := op.Responses["403"]; !ok {
+			op.Responses["403"] = errorResponse("The API key may not use the namespace")
+		}
+		op.Responses["429"] = errorResponse("Rate limit, concurrent job limit or character budget exceeded")
+		return op
+	}
+
+	idParam := openapi.PathParam("id", "Context ID of the word or sentence", &openapi.Schema{Type: "integer"})
+	wav := openapi.Binary("audio/wav")
+
+	doc.Add(http.MethodGet, "/api/v1/health", openapi.Operation{
+		Summary:     "Liveness check",
+		OperationID: "health",
+		Tags:        []string{"status"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The process is running", Content: doc.JSON(map[string]string{})},
+		},
+	})
+	doc.Add(http.MethodGet, "/api/v1/ready", openapi.Operation{
+		Summary: "Readiness check of storage, workers and providers",
+		Description: "Provider credentials are checked at most every 30 seconds, the last result being " +
+			"reported in between.",
+		OperationID: "ready",
+		Tags:        []string{"status"},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Ready, possibly degraded", Content: doc.JSON(readyResponse{})},
+			"503": {Description: "A required dependency is unavailable", Content: doc.JSON(readyResponse{})},
+		},
+	})
+
+	// Reports of sweep jobs, which Job.report holds without naming their type
+	doc.Schema(ReconcileReport{})
+	doc.Schema(retention.Report{})
+
+	for _, kind := range []string{"word", "sentence"} {
+		tag := []string{kind}
+
+		doc.Add(http.MethodPost, "/api/v1/process/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Synthesize and store a clip for each " + kind,
+			OperationID: "process_" + kind,
+			Description: "Without async or a callback the response is sent once the job has finished, " +
+				"so its job_id is only useful afterwards. Set async to get the job_id at once and follow " +
+				"the job at /api/v1/jobs/{id}.",
+			Tags:        tag,
+			RequestBody: doc.Body(ProcessRequest{}),
+			Responses: map[string]openapi.Response{
+				"201": {Description: "All clips were stored", Content: doc.JSON(ProcessResponse{})},
+				"202": {Description: "Accepted for processing with async or a callback", Content: doc.JSON(ProcessResponse{})},
+				"400": errorResponse("Invalid request, or a callback url that is not allowed"),
+				"500": errorResponse("Synthesis or upload failed"),
+				"503": errorResponse("The synthesis queue is full"),
+			},
+		}))
+		doc.Add(http.MethodGet, "/api/v1/get/{id}/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Download a stored " + kind + " clip",
+			OperationID: "get_" + kind,
+			Tags:        tag,
+			Parameters:  []openapi.Parameter{idParam},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "The clip", Content: wav},
+				"206": {Description: "The requested byte range", Content: wav},
+				"304": {Description: "Not modified since the given ETag or date"},
+				"400": errorResponse("Invalid id"),
+				"404": errorResponse("No clip is stored"),
+			},
+		}))
+		doc.Add(http.MethodDelete, "/api/v1/audio/{id}/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Delete a stored " + kind + " clip, keeping its history",
+			Description: "Needs an admin key or one with can_delete, and is only served when authentication is enabled.",
+			OperationID: "delete_" + kind,
+			Tags:        tag,
+			Parameters:  []openapi.Parameter{idParam},
+			Responses: map[string]openapi.Response{
+				"204": {Description: "Deleted"},
+				"400": errorResponse("Invalid id"),
+				"403": errorResponse("The API key may not delete clips or use the namespace"),
+				"404": errorResponse("No clip is stored"),
+			},
+		}))
+		doc.Add(http.MethodGet, "/api/v1/audio/{id}/"+kind+"/history", withAuthErrors(openapi.Operation{
+			Summary:     "List the current and earlier versions of a " + kind + " clip",
+			OperationID: "history_" + kind,
+			Tags:        tag,
+			Parameters:  []openapi.Parameter{idParam},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Versions, newest first", Content: doc.JSON(HistoryResponse{})},
+				"400": errorResponse("Invalid id"),
+				"404": errorResponse("No clip or history is stored"),
+			},
+		}))
+		doc.Add(http.MethodPost, "/api/v1/audio/{id}/"+kind+"/rollback", withAuthErrors(openapi.Operation{
+			Summary:     "Restore an earlier version of a " + kind + " clip",
+			OperationID: "rollback_" + kind,
+			Tags:        tag,
+			Parameters:  []openapi.Parameter{idParam},
+			RequestBody: doc.Body(RollbackRequest{}),
+			Responses: map[string]openapi.Response{
+				"200": {Description: "The version is current again", Content: doc.JSON(RollbackResponse{})},
+				"400": errorResponse("Invalid request"),
+				"404": errorResponse("No such version"),
+			},
+		}))
+		doc.Add(http.MethodGet, "/api/v1/audio/{id}/"+kind+"/url", withAuthErrors(openapi.Operation{
+			Summary:     "Issue a signed, expiring URL to download a " + kind + " clip without an API key",
+			OperationID: "url_" + kind,
+			Tags:        tag,
+			Parameters:  []openapi.Parameter{idParam},
+			Responses: map[string]openapi.Response{
+				"200": {Description: "The signed URL", Content: doc.JSON(ClipURLResponse{})},
+				"400": errorResponse("Invalid id"),
+				"404": errorResponse("No clip is stored"),
+				"503": errorResponse("Signed URLs are not enabled"),
+			},
+		}))
+		doc.Add(http.MethodPost, "/api/v1/audio/missing/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Find which " + kind + "s have no stored clip, according to the manifest",
+			OperationID: "missing_" + kind,
+			Tags:        tag,
+			RequestBody: doc.Body(MissingAudioRequest{}),
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Context IDs without audio, in request order", Content: doc.JSON(MissingAudioResponse{})},
+				"400": errorResponse("Invalid request"),
+				"503": errorResponse("No manifest database is configured"),
+			},
+		}))
+		doc.Add(http.MethodPost, "/api/v1/regenerate/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Re-synthesize individual " + kind + " clips",
+			OperationID: "regenerate_" + kind,
+			Tags:        tag,
+			RequestBody: doc.Body(RegenerateRequest{}),
+			Responses: map[string]openapi.Response{
+				"200": {Description: "Every clip was regenerated", Content: doc.JSON(RegenerateResponse{})},
+				"207": {Description: "Some clips failed and kept their previous audio", Content: doc.JSON(RegenerateResponse{})},
+				"400": errorResponse("Invalid request or a voice the engine is not configured with"),
+				"500": {Description: "Every clip failed", Content: doc.JSON(RegenerateResponse{})},
+				"503": errorResponse("The synthesis queue is full"),
+			},
+		}))
+		doc.Add(http.MethodPost, "/api/v1/reconcile/"+kind, withAuthErrors(openapi.Operation{
+			Summary:     "Check that " + kind + " clips exist with a plausible length and regenerate the rest",
+			OperationID: "reconcile_" + kind,
+			Tags:        tag,
+			RequestBody: doc.Body(ReconcileRequest{}),
+			Description: "The check runs as a job whose report, once it has finished, is a ReconcileReport. " +
+				"Unless this is a dry run, the report names the job regenerating the problem clips.",
+			Responses: map[string]openapi.Response{
+				"202": {Description: "The job checking the clips", Content: doc.JSON(ProcessResponse{})},
+				"400": errorResponse("Invalid request"),
+				"503": errorResponse("No items were given and no manifest database is configured"),
+			},
+		}))
+	}
+
+	doc.Add(http.MethodPost, "/api/v1/preview", withAuthErrors(openapi.Operation{
+		Summary:     "Synthesize a trimmed clip without storing it",
+		OperationID: "preview",
+		Tags:        []string{"preview"},
+		RequestBody: doc.Body(PreviewRequest{}),
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The clip", Content: wav},
+			"400": errorResponse("Invalid request or a voice the engine is not configured with"),
+			"500": errorResponse("Synthesis failed"),
+			"503": errorResponse("The synthesis queue is full"),
+		},
+	}))
+	doc.Add(http.MethodPost, "/api/v1/preview/stream", withAuthErrors(openapi.Operation{
+		Summary:     "Stream an untrimmed clip as the provider produces it",
+		OperationID: "preview_stream",
+		Tags:        []string{"preview"},
+		RequestBody: doc.Body(PreviewRequest{}),
+		Responses: map[string]openapi.Response{
+			"200": {Description: "WAV audio whose header may not declare a length", Content: wav},
+			"400": errorResponse("Invalid request or a voice the engine is not configured with"),
+			"500": errorResponse("Synthesis failed"),
+			"503": errorResponse("The synthesis queue is full"),
+		},
+	}))
+	doc.Add(http.MethodGet, "/api/v1/jobs/{id}", withAuthErrors(openapi.Operation{
+		Summary:     "Status of a synthesis job",
+		OperationID: "get_job",
+		Tags:        []string{"jobs"},
+		Parameters:  []openapi.Parameter{openapi.PathParam("id", "Job ID", &openapi.Schema{Type: "string"})},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The job", Content: doc.JSON(Job{})},
+			"404": errorResponse("No such job, or it belongs to another client"),
+		},
+	}))
+	doc.Add(http.MethodGet, "/api/v1/jobs/{id}/events", withAuthErrors(openapi.Operation{
+		Summary:     "Server-Sent Events of each word's progress, then a done event with the job",
+		OperationID: "get_job_events",
+		Tags:        []string{"jobs"},
+		Parameters:  []openapi.Parameter{openapi.PathParam("id", "Job ID", &openapi.Schema{Type: "string"})},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "progress events carry a ProgressEvent, done carries the Job", Content: map[string]openapi.MediaType{
+				"text/event-stream": {Schema: doc.Schema(ProgressEvent{})},
+			}},
+			"404": errorResponse("No such job, or it belongs to another client"),
+		},
+	}))
+	doc.Add(http.MethodGet, "/api/v1/jobs/{id}/delivery", withAuthErrors(openapi.Operation{
+		Summary:     "Delivery log of a job's callback",
+		OperationID: "get_job_delivery",
+		Description: "The log and pending retries are kept in memory only: they are lost when the service " +
+			"restarts, and retries still waiting then are abandoned.",
+		Tags:       []string{"jobs"},
+		Parameters: []openapi.Parameter{openapi.PathParam("id", "Job ID", &openapi.Schema{Type: "string"})},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Every attempt so far", Content: doc.JSON(webhook.Delivery{})},
+			"404": errorResponse("No such job, or it has no callback"),
+		},
+	}))
+	doc.Add(http.MethodGet, "/api/v1/usage", withAuthErrors(openapi.Operation{
+		Summary:     "Billable character usage and budgets",
+		OperationID: "usage",
+		Tags:        []string{"usage"},
+		Parameters: []openapi.Parameter{
+			openapi.QueryParam("period", "Aggregation period", &openapi.Schema{Type: "string", Enum: []string{"daily", "monthly"}}),
+			openapi.QueryParam("provider", "Only this provider", &openapi.Schema{Type: "string"}),
+			openapi.QueryParam("caller", "Only this caller, admins only", &openapi.Schema{Type: "string"}),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "Usage entries", Content: doc.JSON(UsageResponse{})},
+			"400": errorResponse("Invalid period"),
+		},
+	}))
+	doc.Add(http.MethodPost, "/api/v1/anki/media", withAuthErrors(openapi.Operation{
+		Summary: "Bundle stored clips as Anki media: numbered files, the media map and " +
+			"sound_tags.json with the [sound:] tag of each clip, ready to merge into an .apkg",
+		OperationID: "anki_media",
+		Tags:        []string{"anki"},
+		RequestBody: doc.Body(MediaBundleRequest{}),
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The media bundle", Content: openapi.Binary("application/zip")},
+			"400": errorResponse("Invalid request or a repeated filename"),
+			"404": errorResponse("A requested clip is not stored"),
+			"500": errorResponse("Reading a clip failed"),
+		},
+	}))
+	doc.Add(http.MethodGet, signing.Route+"{name}", openapi.Operation{
+		Summary:     "Download a clip with a signed URL, for storage backends that cannot sign their own",
+		OperationID: "signed_clip",
+		Tags:        []string{"clips"},
+		Parameters: []openapi.Parameter{
+			openapi.PathParam("name", "Blob name of the clip, slashes included", &openapi.Schema{Type: "string"}),
+			openapi.QueryParam(signing.ParamExpires, "Unix time the URL expires at", &openapi.Schema{Type: "integer"}),
+			openapi.QueryParam(signing.ParamSignature, "Hex HMAC-SHA256 of the name and expiry", &openapi.Schema{Type: "string"}),
+		},
+		Responses: map[string]openapi.Response{
+			"200": {Description: "The clip", Content: wav},
+			"206": {Description: "The requested byte range", Content: wav},
+			"304": {Description: "Not modified since the given ETag or date"},
+			"403": errorResponse("The signature is invalid or the URL has expired"),
+			"404": errorResponse("No clip is stored"),
+		},
+	})
+	doc.Add(http.MethodPost, "/api/v1/admin/retention", withAuthErrors(openapi.Operation{
+		Summary: "Delete orphaned clips and expired versions, or report them in a dry run",
+		Description: "Only served when authentication is enabled. The sweep runs as a job whose report, " +
+			"once it has finished, is a retention Report of what was found and deleted.",
+		OperationID: "retention",
+		Tags:        []string{"admin"},
+		RequestBody: doc.Body(RetentionRequest{}),
+		Responses: map[string]openapi.Response{
+			"202": {Description: "The job running the sweep", Content: doc.JSON(ProcessResponse{})},
+			"400": errorResponse("Invalid request, neither live ids nor a maximum age, or an empty live list without allow_empty"),
+			"403": errorResponse("The client is not an admin, or may not use the namespace"),
+		},
+	}))
+
+	return doc
+}
+
+// handleOpenAPIRequest serves the document built once at startup
+func handleOpenAPIRequest(spec []byte) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", spec)
+	}
+}
+
+func marshalOpenAPI() ([]byte, error) {
+	return json.MarshalIndent(openAPIDocument(), "", "  ")
+}
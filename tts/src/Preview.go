@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"tts/src/accounting"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PreviewRequest struct {
+	Engine        string   `json:"engine" binding:"omitempty,oneof=azure google"`
+	Gender        string   `json:"gender" binding:"omitempty,oneof=male female any"`
+	Voice         string   `json:"voice"`
+	Text          string   `json:"text" binding:"required"`
+	Pronunciation string   `json:"pronunciation" binding:"omitempty,pinyin"`
+	RatePercent   *float64 `json:"rate_percent" binding:"omitempty,min=-50,max=100"`
+}
+
+// bindPreviewRequest parses a preview request and resolves its engine, writing
+// the error response itself when it fails
+func bindPreviewRequest(c *gin.Context) (PreviewRequest, *synthesis.Engine, bool) {
+	var req PreviewRequest
+	if !bindJSON(c, &req) {
+		return req, nil, false
+	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
+
+	engine, ok := engineParam(c, req.Engine)
+	if !ok || !voiceParam(c, engine, req.Voice) {
+		return req, nil, false
+	}
+	return req, engine, true
+}
+
+// writeSynthesisError maps errors from queued synthesis to a response
+func writeSynthesisError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.QueueFull, err.Error())
+	case errors.Is(err, synthesis.ErrUnknownVoice):
+		apierror.Abort(c, http.StatusBadRequest, apierror.UnknownVoice, err.Error())
+	case errors.Is(err, ErrPoolClosed):
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.ShuttingDown, err.Error())
+	case errors.Is(err, accounting.ErrBudgetExceeded):
+		apierror.Abort(c, http.StatusTooManyRequests, apierror.BudgetExceeded, err.Error())
+	default:
+		apierror.Abort(c, http.StatusInternalServerError, apierror.SynthesisFailed, err.Error())
+	}
+}
+
+// handlePreviewRequest synthesizes one word or sentence and returns the audio
+// directly, without writing anything to blob storage
+func handlePreviewRequest(c *gin.Context) {
+	req, engine, ok := bindPreviewRequest(c)
+	if !ok {
+		return
+	}
+
+	word := tts.Word{Text: req.Text, Pronunciation: req.Pronunciation}
+	options := tts.SynthesisOptions{RatePercent: req.RatePercent}
+
+	var audio []byte
+	err := workers.Submit(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		audio, err = engine.Preview(ctx, word, req.Voice, req.Gender, options)
+		return err
+	})
+	if err != nil {
+		writeSynthesisError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "audio/wav", audio)
+}
+
+// handlePreviewStreamRequest is handlePreviewRequest forwarding audio to the
+// client with chunked transfer encoding as soon as the provider sends it
+func handlePreviewStreamRequest(c *gin.Context) {
+	req, engine, ok := bindPreviewRequest(c)
+	if !ok {
+		return
+	}
+
+	word := tts.Word{Text: req.Text, Pronunciation: req.Pronunciation}
+	options := tts.SynthesisOptions{RatePercent: req.RatePercent}
+
+	// The worker is held until the audio has been copied to the client, so
+	// streaming previews count against the pool like every other synthesis
+	done, err := workers.Enqueue(c.Request.Context(), func(ctx context.Context) error {
+		stream, err := engine.PreviewStream(ctx, word, req.Voice, req.Gender, options)
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Type", "audio/wav")
+		c.Status(http.StatusOK)
+		copyAudio(ctx, c.Writer, stream)
+		return nil
+	})
+	if err != nil {
+		writeSynthesisError(c, err)
+		return
+	}
+	// Waited for even once the client has gone, as the worker writes to c
+	if err := <-done; err != nil {
+		writeSynthesisError(c, err)
+	}
+}
+
+// copyAudio forwards stream to the client, flushing after every read
+func copyAudio(ctx context.Context, w gin.ResponseWriter, stream io.Reader) {
+	buf := make([]byte, 8*1024)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			w.Flush()
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logging.FromContext(ctx).Error("audio stream interrupted", "error", err)
+			}
+			return
+		}
+	}
+}
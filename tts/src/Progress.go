@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"tts/src/apierror"
+	"tts/src/synthesis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// progressBuffer is how many events a subscriber may fall behind before it is
+// dropped. Dropped clients reconnect with Last-Event-ID and are replayed the rest.
+const progressBuffer = 256
+
+// progressHeartbeat keeps idle event streams open through proxies
+const progressHeartbeat = 15 * time.Second
+
+// ProgressEvent is one word of a job reaching a stage. Done counts the words
+// that were uploaded or failed so far.
+type ProgressEvent struct {
+	Seq   int    `json:"seq"`
+	Index int    `json:"index"`
+	Id    int    `json:"context_id"`
+	Stage string `json:"stage"`
+	URL   string `json:"url,omitempty"`
+	Error string `json:"error,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// progressFeed keeps the events of a running job for replay and fans them out
+// to subscribers. It is guarded by the JobStore lock and dropped once the job
+// finishes, when the job's results carry the outcome of every word.
+type progressFeed struct {
+	events      []ProgressEvent
+	finished    []bool
+	done        int
+	subscribers map[chan ProgressEvent]struct{}
+}
+
+func newProgressFeed(words int) *progressFeed {
+	return &progressFeed{finished: make([]bool, words), subscribers: make(map[chan ProgressEvent]struct{})}
+}
+
+// Publish records a progress event of a running job. A word that already
+// finished ignores later events.
+func (s *JobStore) Publish(id string, event ProgressEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, ok := s.feeds[id]
+	if !ok || event.Index < 0 || event.Index >= len(feed.finished) || feed.finished[event.Index] {
+		return
+	}
+	if event.Stage == synthesis.StageUploaded || event.Stage == synthesis.StageFailed {
+		feed.finished[event.Index] = true
+		feed.done++
+	}
+
+	event.Seq = len(feed.events) + 1
+	event.Done = feed.done
+	event.Total = len(feed.finished)
+	feed.events = append(feed.events, event)
+
+	for subscriber := range feed.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			delete(feed.subscribers, subscriber)
+			close(subscriber)
+		}
+	}
+}
+
+// Subscribe returns the events of a job after sequence number after, and a
+// channel of the ones that follow. The channel is closed when the job finishes
+// or the subscriber falls behind. cancel must be called once done.
+func (s *JobStore) Subscribe(id string, after int) ([]ProgressEvent, <-chan ProgressEvent, func(), bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return nil, nil, nil, false
+	}
+
+	events := make(chan ProgressEvent, progressBuffer)
+	feed, ok := s.feeds[id]
+	if !ok {
+		// Finished already
+		close(events)
+		return nil, events, func() {}, true
+	}
+
+	var replay []ProgressEvent
+	if after >= 0 && after < len(feed.events) {
+		replay = append(replay, feed.events[after:]...)
+	}
+	feed.subscribers[events] = struct{}{}
+
+	cancel := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := feed.subscribers[events]; ok {
+			delete(feed.subscribers, events)
+			close(events)
+		}
+	}
+	return replay, events, cancel, true
+}
+
+// closeFeed ends the event streams of a finished job
+func (s *JobStore) closeFeed(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropFeed(id)
+}
+
+func (s *JobStore) dropFeed(id string) {
+	feed, ok := s.feeds[id]
+	if !ok {
+		return
+	}
+	for subscriber := range feed.subscribers {
+		close(subscriber)
+	}
+	feed.subscribers = nil
+	delete(s.feeds, id)
+}
+
+// handleJobEventsRequest streams the progress of a job as Server-Sent Events.
+// Each word's stages arrive as "progress" events, followed by a single "done"
+// event with the finished job. A job that already finished only sends "done".
+func handleJobEventsRequest(c *gin.Context) {
+	job, ok := visibleJob(c.Request.Context(), c.Param("id"))
+	if !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Job not found")
+		return
+	}
+
+	after, _ := strconv.Atoi(c.GetHeader("Last-Event-ID"))
+	replay, events, cancel, ok := jobs.Subscribe(job.ID, after)
+	if !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Job not found")
+		return
+	}
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range replay {
+		writeSSE(c.Writer, strconv.Itoa(event.Seq), "progress", event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(progressHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				// Either the job finished or this client fell behind and should reconnect
+				if job, ok := jobs.Get(job.ID); ok && job.FinishedAt != nil {
+					writeSSE(c.Writer, "", "done", job)
+					c.Writer.Flush()
+				}
+				return
+			}
+			writeSSE(c.Writer, strconv.Itoa(event.Seq), "progress", event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": keepalive\n\n")
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func writeSSE(w io.Writer, id, event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
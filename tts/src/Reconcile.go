@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/namespace"
+	"tts/src/storage"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Problems found by a reconciliation sweep
+const (
+	ClipMissing  = "missing"
+	ClipEmpty    = "empty"
+	ClipTooShort = "too_short"
+	ClipTooLong  = "too_long"
+)
+
+// Plausible clip durations. Anything shorter is a mis-split fragment, anything
+// longer swallowed its neighbours.
+const (
+	minClipSeconds     = 0.15
+	maxWordSeconds     = 15
+	maxSentenceSeconds = 90
+)
+
+// reconcileParallelism is how many clips are checked at once
+const reconcileParallelism = 8
+
+// ReconcileItem is a word or sentence expected to have audio. Text is only
+// needed to regenerate clips whose text is not known from their metadata or
+// the manifest.
+type ReconcileItem struct {
+	Id            int    `json:"context_id" binding:"gte=0"`
+	Text          string `json:"text"`
+	Pronunciation string `json:"pronunciation" binding:"omitempty,pinyin"`
+}
+
+type ReconcileRequest struct {
+	// Items are checked, or every clip the manifest knows of when empty
+	Items  []ReconcileItem `json:"items" binding:"omitempty,max=10000,dive"`
+	Engine string          `json:"engine" binding:"omitempty,oneof=azure google"`
+	Gender string          `json:"gender" binding:"omitempty,oneof=male female any"`
+	// DryRun reports problems without regenerating anything
+	DryRun bool `json:"dry_run"`
+}
+
+// ClipProblem is a missing or broken clip
+type ClipProblem struct {
+	Id              int     `json:"context_id"`
+	Problem         string  `json:"problem"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Requeued        bool    `json:"requeued"`
+	Error           string  `json:"error,omitempty"`
+}
+
+// ReconcileReport is what a reconciliation found, kept on its job
+type ReconcileReport struct {
+	Checked  int           `json:"checked"`
+	Problems []ClipProblem `json:"problems"`
+	// RegenerateJobID is the job regenerating the problem clips, if any were queued
+	RegenerateJobID string `json:"regenerate_job_id,omitempty"`
+}
+
+// handleReconcileRequest starts a job checking that every expected word or
+// sentence has a stored clip of plausible length. Unless it is a dry run, the
+// job then queues another regenerating those that are missing or broken, one
+// word at a time so a bad split cannot repeat.
+func handleReconcileRequest(c *gin.Context) {
+	var req ReconcileRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
+	if len(req.Items) == 0 && manifest == nil {
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.ManifestDisabled,
+			"Items are required when no manifest database is configured")
+		return
+	}
+
+	engine, ok := engineParam(c, req.Engine)
+	if !ok {
+		return
+	}
+
+	// The sweep outlives c, which gin reuses for later requests
+	sentence := isSentenceRoute(c)
+	job := startSweep(c.Request.Context(), JobReconcile, engine.Name(), func(ctx context.Context) (any, error) {
+		return reconcile(ctx, engine, req, sentence)
+	})
+	c.JSON(http.StatusAccepted, ProcessResponse{JobID: job.ID})
+}
+
+// reconcile checks the clips of req and queues the problem ones for regeneration
+func reconcile(ctx context.Context, engine *synthesis.Engine, req ReconcileRequest, sentence bool) (ReconcileReport, error) {
+	kind := synthesis.ClipKind(sentence)
+
+	items := req.Items
+	var known map[int]storage.ExpectedClip
+	if manifest != nil && needsManifest(items) {
+		expected, err := manifest.ExpectedClips(ctx, namespace.FromContext(ctx), kind)
+		if err != nil {
+			return ReconcileReport{}, fmt.Errorf("failed to read manifest: %w", err)
+		}
+		known = make(map[int]storage.ExpectedClip, len(expected))
+		for _, clip := range expected {
+			known[clip.ContextID] = clip
+			if len(req.Items) == 0 {
+				items = append(items, ReconcileItem{Id: clip.ContextID})
+			}
+		}
+	}
+
+	problems, words, err := checkClips(ctx, items, sentence, known)
+	if err != nil {
+		return ReconcileReport{}, fmt.Errorf("failed to check audio: %w", err)
+	}
+	logging.FromContext(ctx).Info("reconciled audio", "kind", kind, "checked", len(items), "problems", len(problems))
+
+	report := ReconcileReport{Checked: len(items), Problems: problems}
+	if req.DryRun || len(words) == 0 {
+		return report, nil
+	}
+
+	// The regeneration keeps the caller's job slot, already kept by the sweep,
+	// until it has finished too
+	job, wait, err := startJob(ctx, kind, engine.Name(), words, regenerateRun(engine, words, req.Gender, sentence))
+	if err != nil {
+		return report, err
+	}
+	go wait()
+
+	for i := range report.Problems {
+		report.Problems[i].Requeued = report.Problems[i].Error == ""
+	}
+	report.RegenerateJobID = job.ID
+	return report, nil
+}
+
+// checkClips checks the clip of each item and reports those missing or of
+// implausible length, along with the words to regenerate for them. Problem
+// clips whose text is unknown are reported with an error instead.
+func checkClips(ctx context.Context, items []ReconcileItem, sentence bool, known map[int]storage.ExpectedClip) ([]ClipProblem, []tts.Word, error) {
+	found := make([]*ClipProblem, len(items))
+	errs := make([]error, len(items))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, reconcileParallelism)
+	for i, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			found[i], errs[i] = checkClip(ctx, synthesis.AudioPath(ctx, strconv.Itoa(item.Id), sentence), sentence)
+		}()
+	}
+	wg.Wait()
+
+	problems := []ClipProblem{}
+	var words []tts.Word
+	for i, problem := range found {
+		if errs[i] != nil {
+			return nil, nil, errs[i]
+		}
+		if problem == nil {
+			continue
+		}
+		problem.Id = items[i].Id
+
+		word := tts.Word{Id: items[i].Id, Text: items[i].Text, Pronunciation: items[i].Pronunciation}
+		if word.Text == "" {
+			word.Text, word.Pronunciation = clipText(ctx, synthesis.AudioPath(ctx, strconv.Itoa(word.Id), sentence), known[word.Id])
+		}
+		if word.Text == "" {
+			problem.Error = "text unknown, pass it in the request to regenerate this clip"
+		} else {
+			words = append(words, word)
+		}
+		problems = append(problems, *problem)
+	}
+	return problems, words, nil
+}
+
+// checkClip returns the problem with the clip at path, or nil if it looks
+// fine. Its length is judged from the blob's size without downloading it.
+func checkClip(ctx context.Context, path string, sentence bool) (*ClipProblem, error) {
+	blob, err := blobDB.OpenTTSAudio(ctx, path)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return &ClipProblem{Problem: ClipMissing}, nil
+		}
+		return nil, err
+	}
+	size := blob.Properties().Size
+	blob.Close()
+
+	duration := tts.WAVDuration(size)
+	maxSeconds := float64(maxWordSeconds)
+	if sentence {
+		maxSeconds = maxSentenceSeconds
+	}
+	switch {
+	case duration <= 0:
+		return &ClipProblem{Problem: ClipEmpty}, nil
+	case duration < minClipSeconds:
+		return &ClipProblem{Problem: ClipTooShort, DurationSeconds: duration}, nil
+	case duration > maxSeconds:
+		return &ClipProblem{Problem: ClipTooLong, DurationSeconds: duration}, nil
+	}
+	return nil, nil
+}
+
+// clipText recovers the text of a clip from its metadata, falling back to the manifest
+func clipText(ctx context.Context, path string, known storage.ExpectedClip) (string, string) {
+	if metadata, err := clips.Metadata(ctx, path); err == nil && metadata != nil && metadata.Text != "" {
+		return metadata.Text, metadata.Pronunciation
+	}
+	return known.Text, known.Pronunciation
+}
+
+// needsManifest reports whether the manifest has to be read, either to list
+// the clips to check or for the text of items given without one
+func needsManifest(items []ReconcileItem) bool {
+	if len(items) == 0 {
+		return true
+	}
+	for _, item := range items {
+		if item.Text == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// regenerateRun is a job regenerating words one at a time, see Engine.Regenerate
+func regenerateRun(engine *synthesis.Engine, words []tts.Word, gender string, sentence bool) jobRun {
+	return func(ctx context.Context, progress synthesis.ProgressFunc) ([]WordResult, error) {
+		regenerated, err := engine.Regenerate(ctx, words, "", gender, sentence, tts.SynthesisOptions{}, progress)
+
+		results := make([]WordResult, len(regenerated))
+		failed := 0
+		for i, result := range regenerated {
+			results[i] = WordResult{Id: result.Id, Status: synthesis.StageUploaded, URL: result.URL}
+			if result.Status == "failed" {
+				results[i].Status, results[i].Error = synthesis.StageFailed, result.Error
+				failed++
+			}
+		}
+		if err != nil {
+			return results, err
+		}
+		if failed > 0 {
+			return results, fmt.Errorf("%d of %d clips could not be regenerated", failed, len(results))
+		}
+		return results, nil
+	}
+}
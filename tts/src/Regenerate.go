@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/storage"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RegenerateRequest struct {
+	Engine      string     `json:"engine" binding:"omitempty,oneof=azure google"`
+	Gender      string     `json:"gender" binding:"omitempty,oneof=male female any"`
+	Voice       string     `json:"voice"`
+	RatePercent *float64   `json:"rate_percent" binding:"omitempty,min=-50,max=100"`
+	Words       []tts.Word `json:"words" binding:"required,min=1,dive"`
+}
+
+type RegenerateResponse struct {
+	Results []synthesis.RegenerateResult `json:"results"`
+}
+
+// handleDeleteRequest removes the stored clip for a word or sentence. Its
+// history is kept, so the clip can be restored with a rollback.
+func handleDeleteRequest(c *gin.Context) {
+	path, ok := clipPathParam(c)
+	if !ok {
+		return
+	}
+
+	if err := clips.Delete(c.Request.Context(), path); err != nil {
+		if storage.IsNotFound(err) {
+			apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio file not found")
+			return
+		}
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to delete audio: %v", err))
+		return
+	}
+
+	logging.FromContext(c.Request.Context()).Info("deleted audio", "path", path)
+	c.Status(http.StatusNoContent)
+}
+
+// handleRegenerateRequest re-synthesizes specific clips, optionally with a
+// corrected pronunciation or a different voice, replacing each stored clip only
+// when its new audio succeeded
+func handleRegenerateRequest(c *gin.Context) {
+	var req RegenerateRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
+
+	engine, ok := engineParam(c, req.Engine)
+	if !ok || !voiceParam(c, engine, req.Voice) {
+		return
+	}
+
+	options := tts.SynthesisOptions{RatePercent: req.RatePercent}
+	sentence := isSentenceRoute(c)
+
+	var results []synthesis.RegenerateResult
+	err := workers.Submit(c.Request.Context(), func(ctx context.Context) error {
+		var err error
+		results, err = engine.Regenerate(ctx, req.Words, req.Voice, req.Gender, sentence, options, nil)
+		return err
+	})
+	if err != nil {
+		// Words regenerated before the budget ran out keep their new audio
+		writeSynthesisError(c, err)
+		return
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Status == "failed" {
+			failed++
+		}
+	}
+
+	status := http.StatusOK
+	switch {
+	case failed == len(results):
+		status = http.StatusInternalServerError
+	case failed > 0:
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, RegenerateResponse{Results: results})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/namespace"
+	"tts/src/retention"
+	"tts/src/synthesis"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionRequest selects the blobs of the request's namespace to collect.
+// Live id lists come from the backend; a kind whose list is omitted is not
+// checked for orphans.
+type RetentionRequest struct {
+	LiveWordIDs     []int `json:"live_word_ids" binding:"omitempty,dive,gte=0"`
+	LiveSentenceIDs []int `json:"live_sentence_ids" binding:"omitempty,dive,gte=0"`
+	// MaxAgeDays expires archived versions older than this many days
+	MaxAgeDays int `json:"max_age_days" binding:"gte=0"`
+	// DryRun reports what would be deleted without deleting it
+	DryRun bool `json:"dry_run"`
+	// AllowEmpty confirms that an empty live list means every clip of its
+	// kind is orphaned and is to be deleted
+	AllowEmpty bool `json:"allow_empty"`
+}
+
+// collector is shared by the admin endpoint and the periodic sweep
+var collector *retention.Collector
+
+// handleRetentionRequest starts a job reporting or deleting orphaned clips
+// and expired versions
+func handleRetentionRequest(c *gin.Context) {
+	var req RetentionRequest
+	if !bindJSON(c, &req) {
+		return
+	}
+	if req.LiveWordIDs == nil && req.LiveSentenceIDs == nil && req.MaxAgeDays == 0 {
+		apierror.Abort(c, http.StatusBadRequest, apierror.ValidationFailed,
+			"live_word_ids, live_sentence_ids or max_age_days is required")
+		return
+	}
+	// A backend query that failed or came back empty must not wipe every clip
+	emptyLive := (req.LiveWordIDs != nil && len(req.LiveWordIDs) == 0) ||
+		(req.LiveSentenceIDs != nil && len(req.LiveSentenceIDs) == 0)
+	if emptyLive && !req.AllowEmpty && !req.DryRun {
+		apierror.Abort(c, http.StatusBadRequest, apierror.ValidationFailed,
+			"an empty live id list deletes every clip of its kind, set allow_empty to confirm")
+		return
+	}
+
+	ctx := c.Request.Context()
+	policy := retention.Policy{
+		Namespace: namespace.FromContext(ctx),
+		Live:      make(map[string]map[int]bool),
+		MaxAge:    time.Duration(req.MaxAgeDays) * 24 * time.Hour,
+	}
+	for kind, ids := range map[string][]int{synthesis.ClipKind(false): req.LiveWordIDs, synthesis.ClipKind(true): req.LiveSentenceIDs} {
+		if ids == nil {
+			continue
+		}
+		live := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			live[id] = true
+		}
+		policy.Live[kind] = live
+	}
+
+	sweep := collector.Sweep
+	if req.DryRun {
+		sweep = collector.Plan
+	}
+	job := startSweep(ctx, JobRetention, "", func(ctx context.Context) (any, error) {
+		report, err := sweep(ctx, policy)
+		if err != nil {
+			return report, fmt.Errorf("retention sweep failed: %w", err)
+		}
+		logging.FromContext(ctx).Info("retention sweep", "dry_run", report.DryRun, "scanned", report.Scanned,
+			"candidates", len(report.Candidates), "deleted", report.Deleted, "failed", report.Failed)
+		return report, nil
+	})
+	c.JSON(http.StatusAccepted, ProcessResponse{JobID: job.ID})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/signing"
+	"tts/src/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClipURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+var (
+	// urlSigner signs the clip URLs handed to clients, nil when signed URLs are disabled
+	urlSigner storage.URLSigner
+	// clipSigner checks the URLs this service serves itself, nil when the store signs its own
+	clipSigner *signing.Signer
+)
+
+// setupSignedURLs lets the store sign URLs when it can, otherwise clips are
+// served by this service under signing.Route
+func setupSignedURLs() {
+	if signer, ok := blobDB.(storage.URLSigner); ok {
+		urlSigner = signer
+		return
+	}
+	clipSigner = signing.NewSigner(cfg.SignedURLs.Secret, cfg.SignedURLs.PublicURL)
+	urlSigner = clipSigner
+}
+
+// signedURLTTL is how long a signed URL stays usable
+func signedURLTTL() time.Duration {
+	return time.Duration(cfg.SignedURLs.TTLSeconds) * time.Second
+}
+
+// clipURL is the URL clients are given for the clip at path: a signed URL when
+// they are enabled, otherwise the URL the store returned when it was saved
+func clipURL(ctx context.Context, path, stored string) string {
+	if urlSigner == nil || stored == "" {
+		return stored
+	}
+	url, err := urlSigner.SignedURL(ctx, path, time.Now().Add(signedURLTTL()))
+	if err != nil {
+		logging.FromContext(ctx).Warn("failed to sign clip url", "path", path, "error", err)
+		return stored
+	}
+	return url
+}
+
+// unsignedURL drops the signature of a signed URL before it is stored, since
+// it grants access to whoever reads it and is useless once expired
+func unsignedURL(url string) string {
+	if urlSigner == nil {
+		return url
+	}
+	url, _, _ = strings.Cut(url, "?")
+	return url
+}
+
+// handleClipURLRequest issues a fresh signed URL for a stored clip
+func handleClipURLRequest(c *gin.Context) {
+	if urlSigner == nil {
+		apierror.Abort(c, http.StatusServiceUnavailable, apierror.SignedURLsOff, "Signed URLs are not enabled")
+		return
+	}
+	path, ok := clipPathParam(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	audio, err := blobDB.OpenTTSAudio(ctx, path)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio file not found")
+			return
+		}
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to retrieve audio: %v", err))
+		return
+	}
+	audio.Close()
+
+	expires := time.Now().Add(signedURLTTL()).Truncate(time.Second)
+	url, err := urlSigner.SignedURL(ctx, path, expires)
+	if err != nil {
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to sign audio url: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, ClipURLResponse{URL: url, ExpiresAt: expires.UTC()})
+}
+
+// handleSignedClipRequest serves a clip to anyone holding an unexpired signed
+// URL for it. The signature covers the full blob name, namespace included.
+func handleSignedClipRequest(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	if _, _, _, ok := storage.ParseClipPath(name); !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio file not found")
+		return
+	}
+
+	now := time.Now()
+	err := clipSigner.Verify(name, c.Query(signing.ParamExpires), c.Query(signing.ParamSignature), now)
+	if errors.Is(err, signing.ErrExpired) {
+		apierror.Abort(c, http.StatusForbidden, apierror.URLExpired, "Signed URL has expired")
+		return
+	}
+	if err != nil {
+		slog.Debug("rejected signed url", "path", name, "error", err)
+		apierror.Abort(c, http.StatusForbidden, apierror.Forbidden, "Signed URL is invalid")
+		return
+	}
+
+	// Caches must not keep the clip past the URL's expiry
+	expires, _ := strconv.ParseInt(c.Query(signing.ParamExpires), 10, 64)
+	c.Header("Cache-Control", "private, max-age="+strconv.FormatInt(expires-now.Unix(), 10))
+	serveClip(c, name, path.Base(name))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"tts/src/anki"
+	"tts/src/apierror"
+	"tts/src/metrics"
+	"tts/src/synthesis"
+	"tts/src/tts"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// setupValidation names fields in binding errors by their json tags and adds
+// the custom rules used in request binding tags
+func setupValidation() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return fmt.Errorf("unexpected validator engine %T", binding.Validator.Engine())
+	}
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	pinyin := regexp.MustCompile(tts.PronunciationPattern)
+	if err := v.RegisterValidation("pinyin", func(fl validator.FieldLevel) bool {
+		return pinyin.MatchString(fl.Field().String())
+	}); err != nil {
+		return err
+	}
+	return v.RegisterValidation("ankifile", func(fl validator.FieldLevel) bool {
+		return anki.ValidFilename(fl.Field().String())
+	})
+}
+
+// bindJSON binds the request body into req, answering 400 with the rejected
+// fields when it does not validate
+func bindJSON(c *gin.Context, req any) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		metrics.Error(metrics.ErrorInvalidRequest)
+		apierror.AbortBind(c, err)
+		return false
+	}
+	return true
+}
+
+// clipPathParam returns the blob path for the :id of a word or sentence route,
+// answering 400 when the id is not numeric
+func clipPathParam(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := strconv.Atoi(id); err != nil {
+		metrics.Error(metrics.ErrorInvalidRequest)
+		apierror.Abort(c, http.StatusBadRequest, apierror.InvalidID, "id must be a number")
+		return "", false
+	}
+	return synthesis.AudioPath(c.Request.Context(), id, isSentenceRoute(c)), true
+}
+
+// isSentenceRoute reports whether the request is for sentence rather than word audio
+func isSentenceRoute(c *gin.Context) bool {
+	return strings.Contains(c.FullPath(), "sentence")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"tts/src/apierror"
+	"tts/src/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventJobFinished is sent once every word of a job was uploaded or failed
+const eventJobFinished = "job.finished"
+
+// Callback asks for the finished job to be POSTed to URL, signed with Secret.
+// URL must pass the dispatcher's host check.
+type Callback struct {
+	URL    string `json:"url" binding:"required,http_url"`
+	Secret string `json:"secret" binding:"required,min=16"`
+}
+
+// JobEvent is the body of a job callback
+type JobEvent struct {
+	Event string `json:"event"`
+	Job   Job    `json:"job"`
+}
+
+// notifyJob delivers the finished job to its callback in the background
+func notifyJob(ctx context.Context, job Job, callback *Callback) {
+	event := JobEvent{Event: eventJobFinished, Job: job}
+	if err := hooks.Send(job.ID, eventJobFinished, callback.URL, callback.Secret, event); err != nil {
+		logging.FromContext(ctx).Error("failed to send job callback", "job", job.ID, "error", err)
+	}
+}
+
+// handleJobDeliveryRequest shows the delivery log of a job's callback
+func handleJobDeliveryRequest(c *gin.Context) {
+	job, ok := visibleJob(c.Request.Context(), c.Param("id"))
+	if !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Job not found")
+		return
+	}
+
+	delivery, ok := hooks.Delivery(job.ID)
+	if !ok {
+		apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Job has no callback")
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
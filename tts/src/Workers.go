@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrQueueFull  = errors.New("synthesis queue is full")
+	ErrPoolClosed = errors.New("synthesis workers are shutting down")
+)
+
+type workItem struct {
+	ctx  context.Context
+	run  func(context.Context) error
+	done chan error
+}
+
+// WorkerPool runs synthesis work on a fixed number of goroutines fed by a bounded queue
+type WorkerPool struct {
+	queue   chan workItem
+	workers int
+	active  atomic.Int32
+	wg      sync.WaitGroup
+
+	// mu keeps Close from closing the queue while an item is being sent
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewWorkerPool(workers, queueSize int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &WorkerPool{
+		queue:   make(chan workItem, queueSize),
+		workers: workers,
+	}
+
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+
+	return p
+}
+
+func (p *WorkerPool) work() {
+	defer p.wg.Done()
+	for item := range p.queue {
+		if err := item.ctx.Err(); err != nil {
+			item.done <- err
+			continue
+		}
+
+		p.active.Add(1)
+		item.done <- item.run(item.ctx)
+		p.active.Add(-1)
+	}
+}
+
+// Submit queues run and waits for it to finish. It returns ErrQueueFull
+// without waiting when there is no room in the queue.
+func (p *WorkerPool) Submit(ctx context.Context, run func(context.Context) error) error {
+	done, err := p.Enqueue(ctx, run)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Enqueue queues run without waiting for it. The returned channel receives
+// its result. It returns ErrQueueFull when there is no room in the queue and
+// ErrPoolClosed once the pool is closed.
+func (p *WorkerPool) Enqueue(ctx context.Context, run func(context.Context) error) (<-chan error, error) {
+	item := workItem{ctx: ctx, run: run, done: make(chan error, 1)}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	select {
+	case p.queue <- item:
+		return item.done, nil
+	default:
+		return nil, ErrQueueFull
+	}
+}
+
+// Workers is the number of goroutines processing the queue
+func (p *WorkerPool) Workers() int {
+	return p.workers
+}
+
+// Active is the number of items currently being processed
+func (p *WorkerPool) Active() int {
+	return int(p.active.Load())
+}
+
+// Queued is the number of items waiting for a worker
+func (p *WorkerPool) Queued() int {
+	return len(p.queue)
+}
+
+// Capacity is the maximum number of items that can wait for a worker
+func (p *WorkerPool) Capacity() int {
+	return cap(p.queue)
+}
+
+// Close stops accepting work and waits for queued items to finish
+func (p *WorkerPool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
@@ -0,0 +1,39 @@
+package accounting
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	azureUnbilledTags  = regexp.MustCompile(`</?(speak|voice)(\s[^>]*)?>`)
+	googleUnbilledTags = regexp.MustCompile(`<mark(\s[^>]*)?/?>|</mark>`)
+)
+
+// BillableCharacters counts the characters a provider will bill for an SSML payload.
+//
+// Azure bills every character of the SSML body except the <speak> and <voice>
+// elements, and counts each CJK ideograph twice. Google bills every character of
+// the SSML including markup, except <mark> elements.
+func BillableCharacters(provider, ssml string) int64 {
+	switch provider {
+	case "azure":
+		body := azureUnbilledTags.ReplaceAllString(ssml, "")
+		var count int64
+		for _, r := range body {
+			if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+				unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+				count += 2
+			} else {
+				count++
+			}
+		}
+		return count
+	case "google":
+		body := googleUnbilledTags.ReplaceAllString(ssml, "")
+		return int64(utf8.RuneCountInString(body))
+	default:
+		return int64(utf8.RuneCountInString(ssml))
+	}
+}
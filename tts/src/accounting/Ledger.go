@@ -0,0 +1,372 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UnknownCaller is recorded when a request carries no caller identity
+const UnknownCaller = "unknown"
+
+var ErrBudgetExceeded = errors.New("character budget exceeded")
+
+// Budgets limits billable characters. A zero value disables that limit.
+type Budgets struct {
+	DailyCharacters         int64 `json:"daily_characters"`
+	MonthlyCharacters       int64 `json:"monthly_characters"`
+	CallerDailyCharacters   int64 `json:"caller_daily_characters"`
+	CallerMonthlyCharacters int64 `json:"caller_monthly_characters"`
+}
+
+type Usage struct {
+	Characters   int64   `json:"characters"`
+	Requests     int64   `json:"requests"`
+	AudioSeconds float64 `json:"audio_seconds"`
+}
+
+// Entry is a usage total for one period, provider, voice and caller
+type Entry struct {
+	Period   string `json:"period"`
+	Provider string `json:"provider"`
+	Voice    string `json:"voice"`
+	Caller   string `json:"caller"`
+	Usage
+}
+
+type usageKey struct {
+	Day      string
+	Provider string
+	Voice    string
+	Caller   string
+}
+
+// Ledger keeps per day usage totals in memory, optionally persisted to a JSON file
+type Ledger struct {
+	mu        sync.Mutex
+	usage     map[usageKey]*Usage
+	reserved  map[reservationKey]int64
+	budgets   Budgets
+	path      string
+	now       func() time.Time
+	saveMu    sync.Mutex
+	saveTimer *time.Timer
+}
+
+// saveDelay batches the usage file writes of requests finishing close together
+const saveDelay = 5 * time.Second
+
+func NewLedger(budgets Budgets, path string) (*Ledger, error) {
+	l := &Ledger{
+		usage:    make(map[usageKey]*Usage),
+		reserved: make(map[reservationKey]int64),
+		budgets:  budgets,
+		path:     path,
+		now:      time.Now,
+	}
+
+	if path != "" {
+		if err := l.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultLedger = &Ledger{usage: make(map[usageKey]*Usage), reserved: make(map[reservationKey]int64), now: time.Now}
+)
+
+// Default returns the process wide ledger used by the providers
+func Default() *Ledger {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLedger
+}
+
+func SetDefault(l *Ledger) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultLedger = l
+}
+
+type callerKey struct{}
+
+// WithCaller attaches the identity usage is billed to
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func CallerFromContext(ctx context.Context) string {
+	if caller, ok := ctx.Value(callerKey{}).(string); ok && caller != "" {
+		return caller
+	}
+	return UnknownCaller
+}
+
+func (l *Ledger) Budgets() Budgets {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.budgets
+}
+
+// Reservation holds characters against the budgets from Check until the
+// request is recorded or released, so concurrent requests cannot all pass the
+// same check
+type Reservation struct {
+	key        reservationKey
+	characters int64
+	done       bool
+}
+
+type reservationKey struct {
+	Day    string
+	Caller string
+}
+
+// Check reserves characters for the caller in ctx, or returns ErrBudgetExceeded
+// if billing them would go over a configured budget. The reservation must be
+// passed to Record once the provider request succeeds, or to Release.
+func (l *Ledger) Check(ctx context.Context, characters int64) (*Reservation, error) {
+	caller := CallerFromContext(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := l.now().UTC().Format(time.DateOnly)
+	month := day[:7]
+
+	var daily, monthly, callerDaily, callerMonthly int64
+	count := func(keyDay, keyCaller string, characters int64) {
+		if keyDay[:7] != month {
+			return
+		}
+		monthly += characters
+		if keyCaller == caller {
+			callerMonthly += characters
+		}
+		if keyDay == day {
+			daily += characters
+			if keyCaller == caller {
+				callerDaily += characters
+			}
+		}
+	}
+	for key, usage := range l.usage {
+		count(key.Day, key.Caller, usage.Characters)
+	}
+	for key, reserved := range l.reserved {
+		count(key.Day, key.Caller, reserved)
+	}
+
+	b := l.budgets
+	switch {
+	case b.DailyCharacters > 0 && daily+characters > b.DailyCharacters:
+		return nil, fmt.Errorf("%w: daily limit of %d", ErrBudgetExceeded, b.DailyCharacters)
+	case b.MonthlyCharacters > 0 && monthly+characters > b.MonthlyCharacters:
+		return nil, fmt.Errorf("%w: monthly limit of %d", ErrBudgetExceeded, b.MonthlyCharacters)
+	case b.CallerDailyCharacters > 0 && callerDaily+characters > b.CallerDailyCharacters:
+		return nil, fmt.Errorf("%w: daily limit of %d for %s", ErrBudgetExceeded, b.CallerDailyCharacters, caller)
+	case b.CallerMonthlyCharacters > 0 && callerMonthly+characters > b.CallerMonthlyCharacters:
+		return nil, fmt.Errorf("%w: monthly limit of %d for %s", ErrBudgetExceeded, b.CallerMonthlyCharacters, caller)
+	}
+
+	r := &Reservation{key: reservationKey{Day: day, Caller: caller}, characters: characters}
+	l.reserved[r.key] += characters
+	return r, nil
+}
+
+// Record settles a reservation as a completed provider request of the caller
+func (l *Ledger) Record(ctx context.Context, r *Reservation, provider, voice string, audioSeconds float64) {
+	key := usageKey{
+		Provider: provider,
+		Voice:    voice,
+		Caller:   CallerFromContext(ctx),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.release(r) {
+		return
+	}
+	key.Day = l.now().UTC().Format(time.DateOnly)
+	usage, ok := l.usage[key]
+	if !ok {
+		usage = &Usage{}
+		l.usage[key] = usage
+	}
+	usage.Characters += r.characters
+	usage.Requests++
+	usage.AudioSeconds += audioSeconds
+
+	l.scheduleSave()
+}
+
+// Release refunds the characters of a reservation whose request failed
+func (l *Ledger) Release(r *Reservation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release(r)
+}
+
+// release drops a reservation, reporting false if it was already settled
+func (l *Ledger) release(r *Reservation) bool {
+	if r == nil || r.done {
+		return false
+	}
+	r.done = true
+	if l.reserved[r.key] -= r.characters; l.reserved[r.key] <= 0 {
+		delete(l.reserved, r.key)
+	}
+	return true
+}
+
+// scheduleSave writes the usage file saveDelay after the first change since
+// the last write, so a busy batch does not rewrite it for every request
+func (l *Ledger) scheduleSave() {
+	if l.path == "" || l.saveTimer != nil {
+		return
+	}
+	l.saveTimer = time.AfterFunc(saveDelay, func() {
+		// Losing a snapshot only loses accounting, never audio, so don't fail requests
+		_ = l.Flush()
+	})
+}
+
+// Flush writes usage recorded since the last save to the usage file
+func (l *Ledger) Flush() error {
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+
+	l.mu.Lock()
+	if l.saveTimer != nil {
+		l.saveTimer.Stop()
+		l.saveTimer = nil
+	}
+	entries := l.entries()
+	l.mu.Unlock()
+
+	if l.path == "" {
+		return nil
+	}
+	return l.save(entries)
+}
+
+// Report aggregates usage by "daily" or "monthly" period. Empty filters match everything.
+func (l *Ledger) Report(period, provider, caller string) ([]Entry, error) {
+	var periodLen int
+	switch period {
+	case "", "daily":
+		periodLen = len(time.DateOnly)
+	case "monthly":
+		periodLen = 7
+	default:
+		return nil, fmt.Errorf("invalid period: %s", period)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	totals := make(map[usageKey]*Usage)
+	for key, usage := range l.usage {
+		if provider != "" && key.Provider != provider {
+			continue
+		}
+		if caller != "" && key.Caller != caller {
+			continue
+		}
+
+		bucket := key
+		bucket.Day = key.Day[:periodLen]
+		total, ok := totals[bucket]
+		if !ok {
+			total = &Usage{}
+			totals[bucket] = total
+		}
+		total.Characters += usage.Characters
+		total.Requests += usage.Requests
+		total.AudioSeconds += usage.AudioSeconds
+	}
+
+	entries := make([]Entry, 0, len(totals))
+	for key, usage := range totals {
+		entries = append(entries, Entry{
+			Period:   key.Day,
+			Provider: key.Provider,
+			Voice:    key.Voice,
+			Caller:   key.Caller,
+			Usage:    *usage,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Period != b.Period {
+			return a.Period > b.Period
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Voice != b.Voice {
+			return a.Voice < b.Voice
+		}
+		return a.Caller < b.Caller
+	})
+
+	return entries, nil
+}
+
+func (l *Ledger) load() error {
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse usage file: %w", err)
+	}
+
+	for _, entry := range entries {
+		usage := entry.Usage
+		l.usage[usageKey{Day: entry.Period, Provider: entry.Provider, Voice: entry.Voice, Caller: entry.Caller}] = &usage
+	}
+	return nil
+}
+
+func (l *Ledger) entries() []Entry {
+	entries := make([]Entry, 0, len(l.usage))
+	for key, usage := range l.usage {
+		entries = append(entries, Entry{
+			Period:   key.Day,
+			Provider: key.Provider,
+			Voice:    key.Voice,
+			Caller:   key.Caller,
+			Usage:    *usage,
+		})
+	}
+	return entries
+}
+
+func (l *Ledger) save(entries []Entry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
@@ -0,0 +1,132 @@
+package anki
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultConnectURL is where the AnkiConnect add-on listens unless configured otherwise
+const DefaultConnectURL = "http://127.0.0.1:8765"
+
+// connectVersion is the AnkiConnect API version requests are written for
+const connectVersion = 6
+
+// Client calls the AnkiConnect add-on of a running Anki
+type Client struct {
+	url  string
+	key  string
+	http *http.Client
+}
+
+// NewClient returns a client for the AnkiConnect server at url. key is only
+// needed when the add-on is configured to require one.
+func NewClient(url, key string) *Client {
+	return &Client{url: url, key: key, http: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Note is a note as returned by notesInfo
+type Note struct {
+	ID        int64                `json:"noteId"`
+	ModelName string               `json:"modelName"`
+	Tags      []string             `json:"tags"`
+	Fields    map[string]NoteField `json:"fields"`
+}
+
+type NoteField struct {
+	Value string `json:"value"`
+	Order int    `json:"order"`
+}
+
+type connectRequest struct {
+	Action  string `json:"action"`
+	Version int    `json:"version"`
+	Params  any    `json:"params,omitempty"`
+	Key     string `json:"key,omitempty"`
+}
+
+type connectResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *string         `json:"error"`
+}
+
+// Version returns the API version of the add-on
+func (c *Client) Version(ctx context.Context) (int, error) {
+	var version int
+	err := c.call(ctx, "version", nil, &version)
+	return version, err
+}
+
+// FindNotes returns the ids of the notes matching an Anki search query
+func (c *Client) FindNotes(ctx context.Context, query string) ([]int64, error) {
+	var ids []int64
+	err := c.call(ctx, "findNotes", map[string]any{"query": query}, &ids)
+	return ids, err
+}
+
+// NotesInfo returns the fields of each note, in the order of ids
+func (c *Client) NotesInfo(ctx context.Context, ids []int64) ([]Note, error) {
+	var notes []Note
+	err := c.call(ctx, "notesInfo", map[string]any{"notes": ids}, &notes)
+	return notes, err
+}
+
+// StoreMediaFile adds data to the collection's media as filename, replacing
+// any file of that name, and returns the name it was stored under
+func (c *Client) StoreMediaFile(ctx context.Context, filename string, data []byte) (string, error) {
+	var stored string
+	err := c.call(ctx, "storeMediaFile", map[string]any{
+		"filename": filename,
+		"data":     base64.StdEncoding.EncodeToString(data),
+	}, &stored)
+	return stored, err
+}
+
+// UpdateNoteFields sets the given fields of a note, leaving the others alone
+func (c *Client) UpdateNoteFields(ctx context.Context, id int64, fields map[string]string) error {
+	return c.call(ctx, "updateNoteFields", map[string]any{
+		"note": map[string]any{"id": id, "fields": fields},
+	}, nil)
+}
+
+// call runs one action and decodes its result into result, unless it is nil
+func (c *Client) call(ctx context.Context, action string, params, result any) error {
+	body, err := json.Marshal(connectRequest{Action: action, Version: connectVersion, Params: params, Key: c.key})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", action, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach ankiconnect: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ankiconnect %s: unexpected status %s", action, resp.Status)
+	}
+
+	var response connectResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("ankiconnect %s: %s", action, *response.Error)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", action, err)
+	}
+	return nil
+}
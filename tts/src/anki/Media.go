@@ -0,0 +1,105 @@
+package anki
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MediaFile names the JSON map in an .apkg from its numbered entries to the
+// filenames the media is stored under in the collection
+const MediaFile = "media"
+
+// invalidFilenameChars are refused by Anki or unsafe on the filesystems it runs on
+const invalidFilenameChars = "/\\:*?\"<>|"
+
+// ValidFilename reports whether name can be used as an Anki media filename
+func ValidFilename(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 120 || name == MediaFile {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || strings.ContainsRune(invalidFilenameChars, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SoundTag is the field content that plays filename in a note
+func SoundTag(filename string) string {
+	return "[sound:" + filename + "]"
+}
+
+// MediaWriter writes media in the layout of an .apkg: each file under its
+// number, and the media map on Close. The result can be merged into a package
+// whose own media is numbered below the first number given.
+type MediaWriter struct {
+	zip   *zip.Writer
+	next  int
+	media map[string]string
+	names map[string]bool
+}
+
+// NewMediaWriter numbers the files written to w from first
+func NewMediaWriter(w io.Writer, first int) *MediaWriter {
+	return &MediaWriter{
+		zip:   zip.NewWriter(w),
+		next:  first,
+		media: make(map[string]string),
+		names: make(map[string]bool),
+	}
+}
+
+// Add stores the contents of r as the media file filename and returns the
+// tag that plays it
+func (m *MediaWriter) Add(filename string, r io.Reader) (string, error) {
+	if !ValidFilename(filename) {
+		return "", fmt.Errorf("invalid media filename %q", filename)
+	}
+	if m.names[filename] {
+		return "", fmt.Errorf("duplicate media filename %q", filename)
+	}
+
+	entry := strconv.Itoa(m.next)
+	w, err := m.zip.Create(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to add %s: %w", filename, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+
+	m.media[entry] = filename
+	m.names[filename] = true
+	m.next++
+	return SoundTag(filename), nil
+}
+
+// WriteFile stores a file that is not media, such as notes for the caller.
+// Anki skips entries the media map does not name.
+func (m *MediaWriter) WriteFile(name string, data []byte) error {
+	w, err := m.zip.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s: %w", name, err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close writes the media map and finishes the archive
+func (m *MediaWriter) Close() error {
+	data, err := json.Marshal(m.media)
+	if err != nil {
+		return fmt.Errorf("failed to encode media map: %w", err)
+	}
+	if err := m.WriteFile(MediaFile, data); err != nil {
+		return err
+	}
+	return m.zip.Close()
+}
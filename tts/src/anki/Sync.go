@@ -0,0 +1,136 @@
+package anki
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// notesPerRequest bounds each notesInfo call on large collections
+const notesPerRequest = 100
+
+var (
+	soundTagPattern = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	htmlTagPattern  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Synthesizer returns the audio of a note's text, with its pronunciation when
+// the note has one
+type Synthesizer func(ctx context.Context, text, pronunciation string) ([]byte, error)
+
+// SyncOptions selects the notes to give audio and the fields involved
+type SyncOptions struct {
+	// Query is an Anki search, such as deck:Mandarin
+	Query string
+	// TextField holds the text spoken, such as Hanzi
+	TextField string
+	// PronunciationField optionally holds numbered pinyin guiding synthesis
+	PronunciationField string
+	// AudioField receives the [sound:] tag
+	AudioField string
+	// Overwrite replaces the sound of notes that already have one
+	Overwrite bool
+	// DryRun finds the notes that would be updated without synthesizing anything
+	DryRun bool
+}
+
+// SyncReport counts what a sync did to the matching notes
+type SyncReport struct {
+	Matched int `json:"matched"`
+	// Skipped notes already had audio or no text
+	Skipped int `json:"skipped"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+}
+
+// Sync synthesizes audio for the notes matching opts.Query that have none,
+// stores it in the collection's media and puts its tag in the audio field.
+// A note that fails is counted and logged, and the others carry on.
+func Sync(ctx context.Context, client *Client, synthesize Synthesizer, opts SyncOptions) (SyncReport, error) {
+	var report SyncReport
+	ids, err := client.FindNotes(ctx, opts.Query)
+	if err != nil {
+		return report, err
+	}
+	report.Matched = len(ids)
+
+	for start := 0; start < len(ids); start += notesPerRequest {
+		notes, err := client.NotesInfo(ctx, ids[start:min(start+notesPerRequest, len(ids))])
+		if err != nil {
+			return report, err
+		}
+		for _, note := range notes {
+			if err := ctx.Err(); err != nil {
+				return report, err
+			}
+
+			updated, err := syncNote(ctx, client, synthesize, opts, note)
+			switch {
+			case err != nil:
+				report.Failed++
+				slog.Error("failed to add audio", "note", note.ID, "error", err)
+			case updated:
+				report.Updated++
+			default:
+				report.Skipped++
+			}
+		}
+	}
+	return report, nil
+}
+
+// syncNote gives one note audio, reporting false when it is skipped
+func syncNote(ctx context.Context, client *Client, synthesize Synthesizer, opts SyncOptions, note Note) (bool, error) {
+	textField, ok := note.Fields[opts.TextField]
+	if !ok {
+		return false, fmt.Errorf("note type %q has no field %q", note.ModelName, opts.TextField)
+	}
+	audioField, ok := note.Fields[opts.AudioField]
+	if !ok {
+		return false, fmt.Errorf("note type %q has no field %q", note.ModelName, opts.AudioField)
+	}
+
+	text := FieldText(textField.Value)
+	if text == "" || (!opts.Overwrite && soundTagPattern.MatchString(audioField.Value)) {
+		return false, nil
+	}
+	if opts.DryRun {
+		slog.Info("would add audio", "note", note.ID, "text", text)
+		return true, nil
+	}
+
+	pronunciation := ""
+	if field, ok := note.Fields[opts.PronunciationField]; ok {
+		pronunciation = FieldText(field.Value)
+	}
+	audio, err := synthesize(ctx, text, pronunciation)
+	if err != nil {
+		return false, err
+	}
+
+	// Named after the audio so a changed clip never reuses a name Anki has synced
+	sum := sha256.Sum256(audio)
+	filename, err := client.StoreMediaFile(ctx, fmt.Sprintf("tts-%d-%s.wav", note.ID, hex.EncodeToString(sum[:4])), audio)
+	if err != nil {
+		return false, err
+	}
+
+	value := strings.TrimSpace(soundTagPattern.ReplaceAllString(audioField.Value, ""))
+	if err := client.UpdateNoteFields(ctx, note.ID, map[string]string{opts.AudioField: value + SoundTag(filename)}); err != nil {
+		return false, err
+	}
+	slog.Debug("added audio", "note", note.ID, "text", text, "file", filename)
+	return true, nil
+}
+
+// FieldText is the plain text of a field, without markup, sounds or entities
+func FieldText(value string) string {
+	value = soundTagPattern.ReplaceAllString(value, "")
+	value = htmlTagPattern.ReplaceAllString(value, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(value)), " ")
+}
@@ -0,0 +1,339 @@
+package anki
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConnect is an AnkiConnect server holding notes and media in memory
+type fakeConnect struct {
+	t   *testing.T
+	key string
+
+	mu      sync.Mutex
+	notes   map[int64]Note
+	media   map[string][]byte
+	actions []string
+	// failures makes an action answer with an AnkiConnect error
+	failures map[string]string
+}
+
+func newFakeConnect(t *testing.T, notes ...Note) (*fakeConnect, *Client) {
+	fake := &fakeConnect{t: t, key: "secret", notes: make(map[int64]Note), media: make(map[string][]byte), failures: make(map[string]string)}
+	for _, note := range notes {
+		fake.notes[note.ID] = note
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, NewClient(server.URL, fake.key)
+}
+
+func (f *fakeConnect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action  string          `json:"action"`
+		Version int             `json:"version"`
+		Params  json.RawMessage `json:"params"`
+		Key     string          `json:"key"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.t.Errorf("undecodable request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.actions = append(f.actions, req.Action)
+
+	reply := func(result any, message string) {
+		response := map[string]any{"result": result, "error": nil}
+		if message != "" {
+			response["error"] = message
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+	if req.Version != connectVersion {
+		reply(nil, "unsupported version")
+		return
+	}
+	if req.Key != f.key {
+		reply(nil, "valid api key must be provided")
+		return
+	}
+	if message, ok := f.failures[req.Action]; ok {
+		reply(nil, message)
+		return
+	}
+
+	switch req.Action {
+	case "version":
+		reply(connectVersion, "")
+	case "findNotes":
+		ids := []int64{}
+		for id := range f.notes {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		reply(ids, "")
+	case "notesInfo":
+		var params struct {
+			Notes []int64 `json:"notes"`
+		}
+		json.Unmarshal(req.Params, &params)
+		notes := []Note{}
+		for _, id := range params.Notes {
+			notes = append(notes, f.notes[id])
+		}
+		reply(notes, "")
+	case "storeMediaFile":
+		var params struct {
+			Filename string `json:"filename"`
+			Data     string `json:"data"`
+		}
+		json.Unmarshal(req.Params, &params)
+		data, err := base64.StdEncoding.DecodeString(params.Data)
+		if err != nil {
+			reply(nil, "invalid base64")
+			return
+		}
+		f.media[params.Filename] = data
+		reply(params.Filename, "")
+	case "updateNoteFields":
+		var params struct {
+			Note struct {
+				ID     int64             `json:"id"`
+				Fields map[string]string `json:"fields"`
+			} `json:"note"`
+		}
+		json.Unmarshal(req.Params, &params)
+		note, ok := f.notes[params.Note.ID]
+		if !ok {
+			reply(nil, "note was not found")
+			return
+		}
+		for name, value := range params.Note.Fields {
+			field := note.Fields[name]
+			field.Value = value
+			note.Fields[name] = field
+		}
+		reply(nil, "")
+	default:
+		reply(nil, "unsupported action")
+	}
+}
+
+// count returns how often action was called
+func (f *fakeConnect) count(action string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, called := range f.actions {
+		if called == action {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeConnect) field(id int64, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.notes[id].Fields[name].Value
+}
+
+func vocabNote(id int64, hanzi, pinyin, audio string) Note {
+	return Note{ID: id, ModelName: "Vocab", Fields: map[string]NoteField{
+		"Hanzi":  {Value: hanzi},
+		"Pinyin": {Value: pinyin},
+		"Audio":  {Value: audio},
+	}}
+}
+
+// recordingSynthesizer speaks text as its bytes and records what it was asked
+func recordingSynthesizer(spoken *[]string) Synthesizer {
+	return func(ctx context.Context, text, pronunciation string) ([]byte, error) {
+		*spoken = append(*spoken, text+"|"+pronunciation)
+		if text == "坏" {
+			return nil, errors.New("synthesis failed")
+		}
+		return []byte("audio of " + text), nil
+	}
+}
+
+var vocabOptions = SyncOptions{Query: "deck:Mandarin", TextField: "Hanzi", PronunciationField: "Pinyin", AudioField: "Audio"}
+
+func TestSync(t *testing.T) {
+	fake, client := newFakeConnect(t,
+		vocabNote(1, "<b>你好</b>", "ni3 hao3", "see also"),
+		vocabNote(2, "谢谢", "xie4 xie5", "[sound:old.wav]"),
+		vocabNote(3, "<br>", "", ""),
+		Note{ID: 4, ModelName: "Cloze", Fields: map[string]NoteField{"Text": {Value: "{{c1::再见}}"}}},
+		vocabNote(5, "坏", "huai4", ""),
+	)
+
+	var spoken []string
+	report, err := Sync(context.Background(), client, recordingSynthesizer(&spoken), vocabOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncReport{Matched: 5, Skipped: 2, Updated: 1, Failed: 2}); report != want {
+		t.Fatalf("report %+v, want %+v", report, want)
+	}
+	// Markup is stripped before synthesis, and notes with audio or no text are not spoken
+	if want := []string{"你好|ni3 hao3", "坏|huai4"}; !slices.Equal(spoken, want) {
+		t.Fatalf("spoke %q, want %q", spoken, want)
+	}
+
+	if len(fake.media) != 1 {
+		t.Fatalf("stored media %v, want one file", fake.media)
+	}
+	var filename string
+	for name, data := range fake.media {
+		filename = name
+		if string(data) != "audio of 你好" {
+			t.Errorf("stored %q", data)
+		}
+	}
+	if !strings.HasPrefix(filename, "tts-1-") || !strings.HasSuffix(filename, ".wav") {
+		t.Errorf("media file named %q", filename)
+	}
+	if got, want := fake.field(1, "Audio"), "see also[sound:"+filename+"]"; got != want {
+		t.Errorf("audio field %q, want %q", got, want)
+	}
+	if got := fake.field(2, "Audio"); got != "[sound:old.wav]" {
+		t.Errorf("existing audio replaced with %q", got)
+	}
+	if n := fake.count("updateNoteFields"); n != 1 {
+		t.Errorf("%d notes updated, want 1", n)
+	}
+}
+
+func TestSyncOverwrite(t *testing.T) {
+	fake, client := newFakeConnect(t, vocabNote(2, "谢谢", "xie4 xie5", "[sound:old.wav] "))
+
+	var spoken []string
+	opts := vocabOptions
+	opts.Overwrite = true
+	report, err := Sync(context.Background(), client, recordingSynthesizer(&spoken), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Updated != 1 {
+		t.Fatalf("report %+v", report)
+	}
+	got := fake.field(2, "Audio")
+	if strings.Contains(got, "old.wav") || strings.Count(got, "[sound:") != 1 {
+		t.Errorf("audio field %q still has the old sound or more than one", got)
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	fake, client := newFakeConnect(t, vocabNote(1, "你好", "", ""), vocabNote(2, "谢谢", "", "[sound:a.wav]"))
+
+	synthesize := func(ctx context.Context, text, pronunciation string) ([]byte, error) {
+		t.Errorf("dry run synthesized %q", text)
+		return nil, nil
+	}
+	opts := vocabOptions
+	opts.DryRun = true
+	report, err := Sync(context.Background(), client, synthesize, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (SyncReport{Matched: 2, Skipped: 1, Updated: 1}); report != want {
+		t.Fatalf("report %+v, want %+v", report, want)
+	}
+	if fake.count("storeMediaFile") != 0 || fake.count("updateNoteFields") != 0 {
+		t.Error("dry run changed the collection")
+	}
+}
+
+func TestSyncBatchesNotesInfo(t *testing.T) {
+	var notes []Note
+	for id := int64(1); id <= notesPerRequest+1; id++ {
+		notes = append(notes, vocabNote(id, "", "", ""))
+	}
+	fake, client := newFakeConnect(t, notes...)
+
+	report, err := Sync(context.Background(), client, nil, vocabOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != len(notes) {
+		t.Fatalf("report %+v", report)
+	}
+	if n := fake.count("notesInfo"); n != 2 {
+		t.Errorf("notesInfo called %d times, want 2", n)
+	}
+}
+
+func TestSyncStopsOnConnectErrors(t *testing.T) {
+	for _, action := range []string{"findNotes", "notesInfo"} {
+		t.Run(action, func(t *testing.T) {
+			fake, client := newFakeConnect(t, vocabNote(1, "你好", "", ""))
+			fake.failures[action] = "collection is not available"
+
+			_, err := Sync(context.Background(), client, recordingSynthesizer(new([]string)), vocabOptions)
+			if err == nil || !strings.Contains(err.Error(), "collection is not available") {
+				t.Fatalf("error %v", err)
+			}
+		})
+	}
+}
+
+func TestSyncCountsFailedWrites(t *testing.T) {
+	for _, action := range []string{"storeMediaFile", "updateNoteFields"} {
+		t.Run(action, func(t *testing.T) {
+			fake, client := newFakeConnect(t, vocabNote(1, "你好", "", ""), vocabNote(2, "谢谢", "", ""))
+			fake.failures[action] = "permission denied"
+
+			report, err := Sync(context.Background(), client, recordingSynthesizer(new([]string)), vocabOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := (SyncReport{Matched: 2, Failed: 2}); report != want {
+				t.Fatalf("report %+v, want %+v", report, want)
+			}
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, client := newFakeConnect(t)
+	if _, err := NewClient(client.url, "wrong").Version(context.Background()); err == nil ||
+		!strings.Contains(err.Error(), "api key") {
+		t.Errorf("wrong key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	if _, err := NewClient(server.URL, "").FindNotes(context.Background(), "deck:x"); err == nil ||
+		!strings.Contains(err.Error(), "unexpected status") {
+		t.Errorf("bad status: %v", err)
+	}
+}
+
+func TestFieldText(t *testing.T) {
+	for value, want := range map[string]string{
+		"<div>你好</div>":                "你好",
+		"你&nbsp;好 [sound:a.wav]":       "你 好",
+		"<b>一</b><br><i>二</i>":         "一 二",
+		"[sound:only.wav]":             "",
+		"  &lt;not a tag&gt;  ":        "<not a tag>",
+		"<span style=\"x\">三</span>\n": "三",
+	} {
+		if got := FieldText(value); got != want {
+			t.Errorf("FieldText(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
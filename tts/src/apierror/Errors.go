@@ -0,0 +1,93 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Codes are stable identifiers clients can branch on. Messages may change, codes may not.
+const (
+	InvalidJSON      = "invalid_json"
+	ValidationFailed = "validation_failed"
+	InvalidID        = "invalid_id"
+	EngineDisabled   = "engine_disabled"
+	UnknownVoice     = "unknown_voice"
+	NotFound         = "not_found"
+	Unauthorized     = "unauthorized"
+	Forbidden        = "forbidden"
+	RateLimited      = "rate_limited"
+	TooManyJobs      = "too_many_jobs"
+	QueueFull        = "queue_full"
+	ShuttingDown     = "shutting_down"
+	BudgetExceeded   = "budget_exceeded"
+	SynthesisFailed  = "synthesis_failed"
+	StorageFailed    = "storage_failed"
+	ManifestDisabled = "manifest_disabled"
+	SignedURLsOff    = "signed_urls_disabled"
+	URLExpired       = "url_expired"
+)
+
+// ErrorResponse is the body of every error the API returns
+type ErrorResponse struct {
+	Code    string       `json:"code"`
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+// FieldError explains why one field of a request body was rejected
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// Abort stops the request with an error response
+func Abort(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, ErrorResponse{Code: code, Error: message})
+}
+
+// AbortBind stops the request with a 400 describing why its body could not be bound
+func AbortBind(c *gin.Context, err error) {
+	var validationErrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &validationErrs):
+		response := ErrorResponse{Code: ValidationFailed}
+		var fields []string
+		for _, fieldErr := range validationErrs {
+			field := fieldPath(fieldErr.Namespace())
+			fields = append(fields, field)
+			response.Details = append(response.Details, FieldError{
+				Field: field,
+				Rule:  fieldErr.Tag(),
+				Param: fieldErr.Param(),
+			})
+		}
+		response.Error = "invalid fields: " + strings.Join(fields, ", ")
+		c.AbortWithStatusJSON(http.StatusBadRequest, response)
+	case errors.As(err, &typeErr):
+		Abort(c, http.StatusBadRequest, InvalidJSON, fmt.Sprintf("%s must not be a JSON %s", typeErr.Field, typeErr.Value))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		Abort(c, http.StatusBadRequest, InvalidJSON, "request body is not valid JSON")
+	default:
+		Abort(c, http.StatusBadRequest, InvalidJSON, err.Error())
+	}
+}
+
+// fieldPath drops the struct name from a validator namespace, turning
+// "ProcessRequest.words[0].text" into "words[0].text"
+func fieldPath(namespace string) string {
+	if _, rest, ok := strings.Cut(namespace, "."); ok {
+		return rest
+	}
+	return namespace
+}
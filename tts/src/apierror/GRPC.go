@@ -0,0 +1,20 @@
+package apierror
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain identifies this service in gRPC error details
+const Domain = "tts"
+
+// Status is the gRPC form of an error response. The stable code travels as the
+// reason of an ErrorInfo detail.
+func Status(grpcCode codes.Code, code, message string) error {
+	st := status.New(grpcCode, message)
+	if detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: code, Domain: Domain}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
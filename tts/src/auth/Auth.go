@@ -0,0 +1,355 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tts/src/accounting"
+	"tts/src/apierror"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/namespace"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is accepted as an alternative to "Authorization: Bearer <key>"
+const APIKeyHeader = "X-API-Key"
+
+// ClientIDHeader names the caller when authentication is disabled
+const ClientIDHeader = "X-Client-ID"
+
+const clientContextKey = "auth.client"
+
+// Client is a caller allowed to use the API. Keys are only ever held as SHA-256 hashes.
+type Client struct {
+	Name              string
+	KeyHash           string
+	RatePerSecond     float64
+	Burst             int
+	MaxConcurrentJobs int
+	Admin             bool
+	// CanDelete lets the client delete stored clips without being an admin
+	CanDelete bool
+	// AnyNamespace lets the client work in every namespace without being an
+	// admin, as callers do while authentication is disabled
+	AnyNamespace bool
+	// Namespaces the client may work in, the first being used when a request
+	// names none. Without any the client only has the default namespace.
+	Namespaces []string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type Authenticator struct {
+	clients map[string]*Client
+	enabled bool
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	jobs    map[string]int
+	now     func() time.Time
+}
+
+// HashKey returns the hex SHA-256 digest stored in configuration for an API key
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAuthenticator builds an authenticator for clients. When enabled is false
+// every request is let through as an anonymous client that is never an admin.
+func NewAuthenticator(enabled bool, clients []Client) (*Authenticator, error) {
+	a := &Authenticator{
+		clients: make(map[string]*Client, len(clients)),
+		enabled: enabled,
+		buckets: make(map[string]*bucket),
+		jobs:    make(map[string]int),
+		now:     time.Now,
+	}
+
+	for i := range clients {
+		client := clients[i]
+		hash := strings.ToLower(client.KeyHash)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("client %s: key hash must be a hex encoded sha256 digest", client.Name)
+		}
+		if _, ok := a.clients[hash]; ok {
+			return nil, fmt.Errorf("client %s: key hash is already used by another client", client.Name)
+		}
+		client.KeyHash = hash
+		a.clients[hash] = &client
+	}
+
+	return a, nil
+}
+
+// Middleware rejects requests without a valid API key and attaches the client
+// identity to the request, then applies the client's request rate limit
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(APIKeyHeader)
+		if header := c.GetHeader("Authorization"); key == "" && strings.HasPrefix(header, "Bearer ") {
+			key = strings.TrimPrefix(header, "Bearer ")
+		}
+
+		client, ok := a.Authenticate(key, c.GetHeader(ClientIDHeader))
+		if !ok {
+			c.Header("WWW-Authenticate", `Bearer realm="tts"`)
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Unauthorized, "missing or invalid api key")
+			return
+		}
+
+		ns, ok := client.Namespace(c.GetHeader(namespace.Header))
+		if !ok {
+			apierror.Abort(c, http.StatusForbidden, apierror.Forbidden,
+				fmt.Sprintf("namespace %q is not allowed for this api key", c.GetHeader(namespace.Header)))
+			return
+		}
+		defer func() {
+			metrics.NamespaceRequest(namespace.Label(ns), c.FullPath(), c.Writer.Status())
+		}()
+
+		c.Set(clientContextKey, client)
+		c.Request = c.Request.WithContext(attachNamespace(Attach(c.Request.Context(), client), ns))
+
+		if wait, ok := a.Take(client); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.RateLimited, "rate limit exceeded")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Attach records client on ctx for accounting, logging and FromContext
+func Attach(ctx context.Context, client *Client) context.Context {
+	ctx = accounting.WithCaller(ctx, client.Name)
+	ctx = logging.With(ctx, "client", client.Name)
+	return withClient(ctx, client)
+}
+
+// Namespace resolves the namespace a request of the client asks for, reporting
+// false when the client may not use it. Admins may use any namespace.
+func (c *Client) Namespace(requested string) (string, bool) {
+	if !namespace.Valid(requested) {
+		return "", false
+	}
+	if requested == namespace.Default {
+		if len(c.Namespaces) > 0 {
+			return c.Namespaces[0], true
+		}
+		return namespace.Default, true
+	}
+	return requested, c.Admin || c.AnyNamespace || slices.Contains(c.Namespaces, requested)
+}
+
+// attachNamespace records the namespace of a request on ctx and its logger
+func attachNamespace(ctx context.Context, ns string) context.Context {
+	if ns != namespace.Default {
+		ctx = logging.With(ctx, "namespace", ns)
+	}
+	return namespace.With(ctx, ns)
+}
+
+// LimitJobs caps how many synthesis requests a client can have in flight
+func (a *Authenticator) LimitJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := ClientFromGin(c)
+		if !a.AcquireJob(client) {
+			apierror.Abort(c, http.StatusTooManyRequests, apierror.TooManyJobs,
+				fmt.Sprintf("too many concurrent jobs (limit %d)", client.MaxConcurrentJobs))
+			return
+		}
+		// The request holds the slot until it ends, and work it hands off
+		// with KeepJob until that finishes too
+		slot := &jobSlot{holders: 1, release: func() { a.ReleaseJob(client) }}
+		defer slot.drop()
+
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), jobSlotKey{}, slot))
+		c.Next()
+	}
+}
+
+// jobSlot is the concurrent job slot LimitJobs took for a request, released
+// once the request and all work kept from it are done
+type jobSlot struct {
+	mu      sync.Mutex
+	holders int
+	release func()
+}
+
+func (s *jobSlot) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holders--
+	if s.holders == 0 {
+		s.release()
+	}
+}
+
+type jobSlotKey struct{}
+
+// KeepJob keeps the job slot of the request ctx belongs to from being
+// released when the request ends, for work that outlives it. The returned
+// func gives it up, the slot being released once every holder has; it does
+// nothing when the request holds none. Work started from kept work may keep
+// the slot again, before giving up its own hold.
+func KeepJob(ctx context.Context) func() {
+	slot, ok := ctx.Value(jobSlotKey{}).(*jobSlot)
+	if !ok {
+		return func() {}
+	}
+	slot.mu.Lock()
+	slot.holders++
+	slot.mu.Unlock()
+	return sync.OnceFunc(slot.drop)
+}
+
+// RequireAdmin rejects clients that are not admins. It must follow Middleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if client := ClientFromGin(c); !client.Admin {
+			apierror.Abort(c, http.StatusForbidden, apierror.Forbidden, "admin access required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireDelete rejects clients that may not delete clips. It must follow Middleware.
+func RequireDelete() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if client := ClientFromGin(c); !client.Admin && !client.CanDelete {
+			apierror.Abort(c, http.StatusForbidden, apierror.Forbidden, "delete permission required")
+			return
+		}
+		c.Next()
+	}
+}
+
+// AcquireJob reserves one of the client's concurrent job slots
+func (a *Authenticator) AcquireJob(client *Client) bool {
+	if client.MaxConcurrentJobs <= 0 {
+		return true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jobs[client.Name] >= client.MaxConcurrentJobs {
+		return false
+	}
+	a.jobs[client.Name]++
+	return true
+}
+
+func (a *Authenticator) ReleaseJob(client *Client) {
+	if client.MaxConcurrentJobs <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.jobs[client.Name] > 0 {
+		a.jobs[client.Name]--
+	}
+}
+
+// Enabled reports whether requests need an API key. Admin-only routes are
+// only served when they do.
+func (a *Authenticator) Enabled() bool {
+	return a.enabled
+}
+
+// Authenticate finds the client holding key. With authentication disabled
+// clientID names the caller instead, and an empty one means anonymous.
+// Callers named this way are never admins, since anyone can claim a name.
+func (a *Authenticator) Authenticate(key, clientID string) (*Client, bool) {
+	if !a.enabled {
+		if clientID != "" {
+			return &Client{Name: clientID, AnyNamespace: true}, true
+		}
+		return anonymous, true
+	}
+
+	if key == "" {
+		return nil, false
+	}
+
+	hash := HashKey(key)
+	for stored, client := range a.clients {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			return client, true
+		}
+	}
+	return nil, false
+}
+
+// Take removes a token from the client's bucket, returning how long to wait when it is empty
+func (a *Authenticator) Take(client *Client) (time.Duration, bool) {
+	if client.RatePerSecond <= 0 {
+		return 0, true
+	}
+
+	burst := float64(client.Burst)
+	if burst < 1 {
+		burst = math.Max(1, client.RatePerSecond)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	b, ok := a.buckets[client.Name]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		a.buckets[client.Name] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*client.RatePerSecond)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / client.RatePerSecond * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+var anonymous = &Client{Name: accounting.UnknownCaller, AnyNamespace: true}
+
+type clientKey struct{}
+
+func withClient(ctx context.Context, client *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// FromContext returns the authenticated client, or the anonymous client when there is none
+func FromContext(ctx context.Context) *Client {
+	if client, ok := ctx.Value(clientKey{}).(*Client); ok {
+		return client
+	}
+	return anonymous
+}
+
+func ClientFromGin(c *gin.Context) *Client {
+	if client, ok := c.Get(clientContextKey); ok {
+		return client.(*Client)
+	}
+	return FromContext(c.Request.Context())
+}
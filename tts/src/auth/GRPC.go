@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"tts/src/apierror"
+	"tts/src/namespace"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryInterceptor is Middleware for gRPC unary calls
+func (a *Authenticator) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authenticateRPC(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is Middleware for gRPC streaming calls
+func (a *Authenticator) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticateRPC(ss.Context())
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticateRPC reads the same credentials as Middleware from call metadata
+func (a *Authenticator) authenticateRPC(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	key := first(strings.ToLower(APIKeyHeader))
+	if header := first("authorization"); key == "" && strings.HasPrefix(header, "Bearer ") {
+		key = strings.TrimPrefix(header, "Bearer ")
+	}
+
+	client, ok := a.Authenticate(key, first(strings.ToLower(ClientIDHeader)))
+	if !ok {
+		return nil, apierror.Status(codes.Unauthenticated, apierror.Unauthorized, "missing or invalid api key")
+	}
+
+	if wait, ok := a.Take(client); !ok {
+		retry := strconv.Itoa(int(math.Ceil(wait.Seconds())))
+		return nil, apierror.Status(codes.ResourceExhausted, apierror.RateLimited,
+			fmt.Sprintf("rate limit exceeded, retry after %ss", retry))
+	}
+
+	ns, ok := client.Namespace(first(namespace.MetadataKey))
+	if !ok {
+		return nil, apierror.Status(codes.PermissionDenied, apierror.Forbidden,
+			fmt.Sprintf("namespace %q is not allowed for this api key", first(namespace.MetadataKey)))
+	}
+
+	return attachNamespace(Attach(ctx, client), ns), nil
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,689 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"tts/src/namespace"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultPath is read when TTS_CONFIG is not set. It is optional.
+const DefaultPath = "/config/tts.yaml"
+
+const defaultBlobURL = "http://localhost:10000/devstoreaccount1"
+
+type Config struct {
+	Listen          string           `yaml:"listen"`
+	GRPC            GRPCConfig       `yaml:"grpc"`
+	LogLevel        string           `yaml:"log_level"`
+	DefaultProvider string           `yaml:"default_provider"`
+	Providers       ProvidersConfig  `yaml:"providers"`
+	Storage         StorageConfig    `yaml:"storage"`
+	Manifest        ManifestConfig   `yaml:"manifest"`
+	Synthesis       SynthesisConfig  `yaml:"synthesis"`
+	Workers         WorkersConfig    `yaml:"workers"`
+	Accounting      AccountingConfig `yaml:"accounting"`
+	Auth            AuthConfig       `yaml:"auth"`
+	Preview         PreviewConfig    `yaml:"preview"`
+	Webhooks        WebhooksConfig   `yaml:"webhooks"`
+	Retention       RetentionConfig  `yaml:"retention"`
+	SignedURLs      SignedURLsConfig `yaml:"signed_urls"`
+}
+
+type GRPCConfig struct {
+	// Listen is the gRPC server address, empty to disable gRPC
+	Listen string `yaml:"listen"`
+}
+
+type ProvidersConfig struct {
+	Azure  AzureConfig  `yaml:"azure"`
+	Google GoogleConfig `yaml:"google"`
+}
+
+type AzureConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	APIKey       string `yaml:"api_key"`
+	Region       string `yaml:"region"`
+	LanguageCode string `yaml:"language_code"`
+	MaleVoice    string `yaml:"male_voice"`
+	FemaleVoice  string `yaml:"female_voice"`
+	// Voices clients may ask for by name besides the male and female voices
+	Voices []string `yaml:"voices"`
+}
+
+type GoogleConfig struct {
+	Enabled         bool   `yaml:"enabled"`
+	CredentialsFile string `yaml:"credentials_file"`
+	LanguageCode    string `yaml:"language_code"`
+	MaleVoice       string `yaml:"male_voice"`
+	FemaleVoice     string `yaml:"female_voice"`
+	// Voices clients may ask for by name besides the male and female voices
+	Voices []string `yaml:"voices"`
+}
+
+type StorageConfig struct {
+	Backend string `yaml:"backend"`
+	// KeepVersions is how many earlier versions of each clip are kept for rollback
+	KeepVersions int                     `yaml:"keep_versions"`
+	Azure        AzureStorageConfig      `yaml:"azure"`
+	Filesystem   FilesystemStorageConfig `yaml:"filesystem"`
+}
+
+type AzureStorageConfig struct {
+	AccountName   string `yaml:"account_name"`
+	AccountKey    string `yaml:"account_key"`
+	BlobURL       string `yaml:"blob_url"`
+	ContainerName string `yaml:"container_name"`
+	// PublicBlobURL is blob_url as clients reach it, used in signed URLs
+	PublicBlobURL string `yaml:"public_blob_url"`
+}
+
+// FilesystemStorageConfig keeps clips in a local directory served at BaseURL
+type FilesystemStorageConfig struct {
+	Root    string `yaml:"root"`
+	BaseURL string `yaml:"base_url"`
+}
+
+// ManifestConfig selects the database recording jobs and stored clips
+type ManifestConfig struct {
+	// Driver is "sqlite" or "mysql" (also for MariaDB), empty to keep no manifest
+	Driver string `yaml:"driver"`
+	// DSN is a file path for sqlite and user:password@tcp(host:3306)/database for mysql
+	DSN string `yaml:"dsn"`
+}
+
+// SynthesisConfig holds the TTSConfig defaults used by every provider
+type SynthesisConfig struct {
+	BreakDurationMs int     `yaml:"break_duration_ms"`
+	SilenceThreshDB float64 `yaml:"silence_thresh_db"`
+	MinSilenceLen   int     `yaml:"min_silence_len_ms"`
+	KeepSilence     int     `yaml:"keep_silence_ms"`
+	SeekStep        int     `yaml:"seek_step"`
+}
+
+type WorkersConfig struct {
+	Count     int `yaml:"count"`
+	QueueSize int `yaml:"queue_size"`
+}
+
+type AccountingConfig struct {
+	UsageFile string        `yaml:"usage_file"`
+	Budgets   BudgetsConfig `yaml:"budgets"`
+}
+
+type BudgetsConfig struct {
+	DailyCharacters         int64 `yaml:"daily_characters"`
+	MonthlyCharacters       int64 `yaml:"monthly_characters"`
+	CallerDailyCharacters   int64 `yaml:"caller_daily_characters"`
+	CallerMonthlyCharacters int64 `yaml:"caller_monthly_characters"`
+}
+
+type PreviewConfig struct {
+	// CacheEntries is how many preview clips each engine keeps in memory
+	CacheEntries int `yaml:"cache_entries"`
+}
+
+// WebhooksConfig controls delivery of job callbacks
+type WebhooksConfig struct {
+	MaxAttempts      int `yaml:"max_attempts"`
+	InitialBackoffMs int `yaml:"initial_backoff_ms"`
+	TimeoutMs        int `yaml:"timeout_ms"`
+	// AllowedHosts limits callback URLs to these hosts, or their subdomains
+	// when written as .example.com. Empty allows any public host.
+	AllowedHosts []string `yaml:"allowed_hosts"`
+	// AllowPrivateNetworks permits callbacks to loopback, private and
+	// link-local addresses, which are refused by default
+	AllowPrivateNetworks bool `yaml:"allow_private_networks"`
+}
+
+// RetentionConfig controls garbage collection of stored blobs
+type RetentionConfig struct {
+	// HistoryMaxAgeDays expires archived clip versions older than this on a
+	// periodic sweep, 0 disables the sweep
+	HistoryMaxAgeDays   int     `yaml:"history_max_age_days"`
+	IntervalHours       int     `yaml:"interval_hours"`
+	DeleteRatePerSecond float64 `yaml:"delete_rate_per_second"`
+	BatchSize           int     `yaml:"batch_size"`
+}
+
+// SignedURLsConfig controls the time-limited clip URLs handed to clients. The
+// azure backend signs them with a SAS, other backends have this service serve
+// them, signed with Secret.
+type SignedURLsConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	TTLSeconds int  `yaml:"ttl_seconds"`
+	// Secret keys the HMAC of URLs served by this service
+	Secret string `yaml:"secret"`
+	// PublicURL is the address clients reach this service at
+	PublicURL string `yaml:"public_url"`
+}
+
+// AuthConfig is on by default. Without it any caller may pick a name with
+// X-Client-ID and reach every namespace, so turning it off takes an explicit
+// enabled: false.
+type AuthConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	Clients []ClientConfig `yaml:"clients"`
+}
+
+// ClientConfig describes one API key holder. The key itself is never stored,
+// only its hex SHA-256 digest (echo -n "$KEY" | sha256sum).
+type ClientConfig struct {
+	Name              string  `yaml:"name"`
+	KeySHA256         string  `yaml:"key_sha256"`
+	RatePerSecond     float64 `yaml:"rate_per_second"`
+	Burst             int     `yaml:"burst"`
+	MaxConcurrentJobs int     `yaml:"max_concurrent_jobs"`
+	Admin             bool    `yaml:"admin"`
+	// CanDelete lets a client that is not an admin delete stored clips
+	CanDelete bool `yaml:"can_delete"`
+	// Namespaces the client may use, the first being its default. Empty means
+	// only the default namespace, unless the client is an admin.
+	Namespaces []string `yaml:"namespaces"`
+}
+
+// Default returns the settings used when neither the file nor the environment sets a value
+func Default() Config {
+	googleCredentials := "/config/google_service.json"
+	_, err := os.Stat(googleCredentials)
+
+	return Config{
+		Listen:          ":8081",
+		GRPC:            GRPCConfig{Listen: ":9091"},
+		LogLevel:        "info",
+		DefaultProvider: "azure",
+		Providers: ProvidersConfig{
+			Azure: AzureConfig{
+				Enabled:      true,
+				LanguageCode: "zh-CN",
+				MaleVoice:    "zh-CN-YunxiaoMultilingualNeural",
+				FemaleVoice:  "zh-CN-XiaoxiaoMultilingualNeural",
+			},
+			Google: GoogleConfig{
+				Enabled:         err == nil,
+				CredentialsFile: googleCredentials,
+				LanguageCode:    "cmn-Hans-CN",
+				MaleVoice:       "cmn-CN-Wavenet-C",
+				FemaleVoice:     "cmn-CN-Wavenet-A",
+			},
+		},
+		Storage: StorageConfig{
+			Backend:      "azure",
+			KeepVersions: 5,
+			Azure: AzureStorageConfig{
+				AccountName:   "devstoreaccount1",
+				AccountKey:    "Eby8vdM02xNOcqFevkbZzGvAafMNCxF+GB0Wm8w5RkWIFhZ4IRHxuRHIFw==",
+				BlobURL:       defaultBlobURL,
+				ContainerName: "tts-audio",
+			},
+		},
+		Synthesis: SynthesisConfig{
+			BreakDurationMs: 500,
+			SilenceThreshDB: -40.0,
+			MinSilenceLen:   350,
+			KeepSilence:     200,
+			SeekStep:        5,
+		},
+		Workers: WorkersConfig{
+			Count:     4,
+			QueueSize: 64,
+		},
+		Auth: AuthConfig{Enabled: true},
+		Preview: PreviewConfig{
+			CacheEntries: 256,
+		},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:      6,
+			InitialBackoffMs: 1000,
+			TimeoutMs:        10000,
+		},
+		Retention: RetentionConfig{
+			IntervalHours:       24,
+			DeleteRatePerSecond: 20,
+			BatchSize:           100,
+		},
+		SignedURLs: SignedURLsConfig{
+			TTLSeconds: 3600,
+		},
+	}
+}
+
+// Load reads the file at TTS_CONFIG (or DefaultPath if it exists), applies
+// environment overrides and validates the result
+func Load() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadForCommand is Load for commands that synthesize or store clips but serve
+// no API, so need no API keys even though the service requires them
+func LoadForCommand() (*Config, error) {
+	cfg, err := Read()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Auth.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Read is Load without validation, for tools that only need some settings and
+// should not require credentials for the rest
+func Read() (*Config, error) {
+	cfg := Default()
+
+	path, required := os.Getenv("TTS_CONFIG"), true
+	if path == "" {
+		path, required = DefaultPath, false
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !required:
+	default:
+		return nil, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	// Running under docker compose means azurite is reachable by its service name
+	if os.Getenv("DOCKER_ENV") == "true" && c.Storage.Azure.BlobURL == defaultBlobURL {
+		c.Storage.Azure.BlobURL = fmt.Sprintf("http://mingxue_azurite:10000/%s", c.Storage.Azure.AccountName)
+	}
+
+	var errs []error
+	setString := func(key string, target *string) {
+		if value := os.Getenv(key); value != "" {
+			*target = value
+		}
+	}
+	setInt := func(key string, target *int) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setInt64 := func(key string, target *int64) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not an integer", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setFloat := func(key string, target *float64) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a number", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	setList := func(key string, target *[]string) {
+		if value := os.Getenv(key); value != "" {
+			*target = nil
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*target = append(*target, item)
+				}
+			}
+		}
+	}
+	setBool := func(key string, target *bool) {
+		if value := os.Getenv(key); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %q is not a boolean", key, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	setString("TTS_LISTEN_ADDR", &c.Listen)
+	if value, ok := os.LookupEnv("TTS_GRPC_ADDR"); ok {
+		c.GRPC.Listen = value
+	}
+	setString("LOG_LEVEL", &c.LogLevel)
+	setString("TTS_DEFAULT_PROVIDER", &c.DefaultProvider)
+
+	setBool("AZURE_ENABLED", &c.Providers.Azure.Enabled)
+	setString("AZURE_API_KEY", &c.Providers.Azure.APIKey)
+	setString("AZURE_REGION", &c.Providers.Azure.Region)
+	setString("AZURE_MALE_VOICE", &c.Providers.Azure.MaleVoice)
+	setString("AZURE_FEMALE_VOICE", &c.Providers.Azure.FemaleVoice)
+	setList("AZURE_VOICES", &c.Providers.Azure.Voices)
+
+	setBool("GOOGLE_ENABLED", &c.Providers.Google.Enabled)
+	setString("GOOGLE_CREDENTIALS_FILE", &c.Providers.Google.CredentialsFile)
+	setString("GOOGLE_MALE_VOICE", &c.Providers.Google.MaleVoice)
+	setString("GOOGLE_FEMALE_VOICE", &c.Providers.Google.FemaleVoice)
+	setList("GOOGLE_VOICES", &c.Providers.Google.Voices)
+
+	setString("STORAGE_BACKEND", &c.Storage.Backend)
+	setInt("STORAGE_KEEP_VERSIONS", &c.Storage.KeepVersions)
+	setString("AZURE_STORAGE_ACCOUNT_NAME", &c.Storage.Azure.AccountName)
+	setString("AZURE_STORAGE_ACCOUNT_KEY", &c.Storage.Azure.AccountKey)
+	setString("AZURE_STORAGE_BLOB_URL", &c.Storage.Azure.BlobURL)
+	setString("AZURE_STORAGE_CONTAINER", &c.Storage.Azure.ContainerName)
+	setString("AZURE_STORAGE_PUBLIC_BLOB_URL", &c.Storage.Azure.PublicBlobURL)
+	setString("STORAGE_FILESYSTEM_ROOT", &c.Storage.Filesystem.Root)
+	setString("STORAGE_FILESYSTEM_BASE_URL", &c.Storage.Filesystem.BaseURL)
+
+	setString("MANIFEST_DRIVER", &c.Manifest.Driver)
+	setString("MANIFEST_DSN", &c.Manifest.DSN)
+
+	setInt("TTS_BREAK_DURATION_MS", &c.Synthesis.BreakDurationMs)
+	setFloat("TTS_SILENCE_THRESH_DB", &c.Synthesis.SilenceThreshDB)
+	setInt("TTS_MIN_SILENCE_LEN_MS", &c.Synthesis.MinSilenceLen)
+	setInt("TTS_KEEP_SILENCE_MS", &c.Synthesis.KeepSilence)
+	setInt("TTS_SEEK_STEP", &c.Synthesis.SeekStep)
+
+	setInt("TTS_WORKERS", &c.Workers.Count)
+	setInt("TTS_QUEUE_SIZE", &c.Workers.QueueSize)
+
+	setString("USAGE_FILE", &c.Accounting.UsageFile)
+	setInt64("BUDGET_DAILY_CHARACTERS", &c.Accounting.Budgets.DailyCharacters)
+	setInt64("BUDGET_MONTHLY_CHARACTERS", &c.Accounting.Budgets.MonthlyCharacters)
+	setInt64("BUDGET_CALLER_DAILY_CHARACTERS", &c.Accounting.Budgets.CallerDailyCharacters)
+	setInt64("BUDGET_CALLER_MONTHLY_CHARACTERS", &c.Accounting.Budgets.CallerMonthlyCharacters)
+
+	setBool("AUTH_ENABLED", &c.Auth.Enabled)
+	// A backend key from the environment, for deployments without a config file
+	if value := os.Getenv("AUTH_BACKEND_KEY_SHA256"); value != "" {
+		i := slices.IndexFunc(c.Auth.Clients, func(client ClientConfig) bool { return client.Name == "backend" })
+		if i < 0 {
+			c.Auth.Clients = append(c.Auth.Clients, ClientConfig{Name: "backend", Admin: true})
+			i = len(c.Auth.Clients) - 1
+		}
+		c.Auth.Clients[i].KeySHA256 = value
+	}
+
+	setInt("PREVIEW_CACHE_ENTRIES", &c.Preview.CacheEntries)
+
+	setInt("WEBHOOK_MAX_ATTEMPTS", &c.Webhooks.MaxAttempts)
+	setInt("WEBHOOK_INITIAL_BACKOFF_MS", &c.Webhooks.InitialBackoffMs)
+	setInt("WEBHOOK_TIMEOUT_MS", &c.Webhooks.TimeoutMs)
+	setList("WEBHOOK_ALLOWED_HOSTS", &c.Webhooks.AllowedHosts)
+	setBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", &c.Webhooks.AllowPrivateNetworks)
+
+	setInt("RETENTION_HISTORY_MAX_AGE_DAYS", &c.Retention.HistoryMaxAgeDays)
+	setInt("RETENTION_INTERVAL_HOURS", &c.Retention.IntervalHours)
+	setFloat("RETENTION_DELETE_RATE_PER_SECOND", &c.Retention.DeleteRatePerSecond)
+	setInt("RETENTION_BATCH_SIZE", &c.Retention.BatchSize)
+
+	setBool("SIGNED_URLS_ENABLED", &c.SignedURLs.Enabled)
+	setInt("SIGNED_URL_TTL_SECONDS", &c.SignedURLs.TTLSeconds)
+	setString("SIGNED_URL_SECRET", &c.SignedURLs.Secret)
+	setString("PUBLIC_URL", &c.SignedURLs.PublicURL)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Listen == "" {
+		fail("listen: must not be empty")
+	}
+	if c.GRPC.Listen != "" && c.GRPC.Listen == c.Listen {
+		fail("grpc.listen: must differ from listen")
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		fail("log_level: must be one of debug, info, warn, error (got %q)", c.LogLevel)
+	}
+
+	azure, google := c.Providers.Azure, c.Providers.Google
+	if !azure.Enabled && !google.Enabled {
+		fail("providers: at least one provider must be enabled")
+	}
+	if !c.ProviderEnabled(c.DefaultProvider) {
+		fail("default_provider: %q is not an enabled provider", c.DefaultProvider)
+	}
+
+	if azure.Enabled {
+		if azure.APIKey == "" {
+			fail("providers.azure.api_key: required when azure is enabled (or set AZURE_API_KEY)")
+		}
+		if azure.Region == "" {
+			fail("providers.azure.region: required when azure is enabled (or set AZURE_REGION)")
+		}
+		if azure.MaleVoice == "" || azure.FemaleVoice == "" {
+			fail("providers.azure: male_voice and female_voice must not be empty")
+		}
+	}
+	if google.Enabled {
+		if _, err := os.Stat(google.CredentialsFile); err != nil {
+			fail("providers.google.credentials_file: %v", err)
+		}
+		if google.MaleVoice == "" || google.FemaleVoice == "" {
+			fail("providers.google: male_voice and female_voice must not be empty")
+		}
+	}
+
+	c.validateStorage(fail)
+
+	s := c.Synthesis
+	if s.BreakDurationMs <= 0 {
+		fail("synthesis.break_duration_ms: must be positive")
+	}
+	if s.SilenceThreshDB >= 0 {
+		fail("synthesis.silence_thresh_db: must be negative")
+	}
+	if s.MinSilenceLen <= 0 {
+		fail("synthesis.min_silence_len_ms: must be positive")
+	}
+	if s.MinSilenceLen > s.BreakDurationMs {
+		fail("synthesis.min_silence_len_ms: must not exceed break_duration_ms or breaks are never detected")
+	}
+	if s.KeepSilence < 0 {
+		fail("synthesis.keep_silence_ms: must not be negative")
+	}
+	if s.SeekStep <= 0 {
+		fail("synthesis.seek_step: must be positive")
+	}
+
+	if c.Workers.Count < 1 {
+		fail("workers.count: must be at least 1")
+	}
+	if c.Workers.QueueSize < 0 {
+		fail("workers.queue_size: must not be negative")
+	}
+
+	if c.Preview.CacheEntries < 0 {
+		fail("preview.cache_entries: must not be negative")
+	}
+
+	if c.Webhooks.MaxAttempts < 1 {
+		fail("webhooks.max_attempts: must be at least 1")
+	}
+	if c.Webhooks.InitialBackoffMs <= 0 {
+		fail("webhooks.initial_backoff_ms: must be positive")
+	}
+	if c.Webhooks.TimeoutMs <= 0 {
+		fail("webhooks.timeout_ms: must be positive")
+	}
+
+	if c.Retention.HistoryMaxAgeDays < 0 {
+		fail("retention.history_max_age_days: must not be negative")
+	}
+	if c.Retention.IntervalHours < 1 {
+		fail("retention.interval_hours: must be at least 1")
+	}
+	if c.Retention.DeleteRatePerSecond <= 0 {
+		fail("retention.delete_rate_per_second: must be positive")
+	}
+	if c.Retention.BatchSize < 1 {
+		fail("retention.batch_size: must be at least 1")
+	}
+
+	if u := c.SignedURLs; u.Enabled {
+		if u.TTLSeconds < 1 {
+			fail("signed_urls.ttl_seconds: must be positive")
+		}
+		if c.Storage.Backend != "azure" {
+			if len(u.Secret) < 32 {
+				fail("signed_urls.secret: at least 32 characters required unless the backend is azure (or set SIGNED_URL_SECRET)")
+			}
+			if !strings.HasPrefix(u.PublicURL, "http://") && !strings.HasPrefix(u.PublicURL, "https://") {
+				fail("signed_urls.public_url: must be an http(s) URL unless the backend is azure (or set PUBLIC_URL)")
+			}
+		}
+	}
+
+	b := c.Accounting.Budgets
+	if b.DailyCharacters < 0 || b.MonthlyCharacters < 0 || b.CallerDailyCharacters < 0 || b.CallerMonthlyCharacters < 0 {
+		fail("accounting.budgets: limits must not be negative")
+	}
+
+	if c.Auth.Enabled && len(c.Auth.Clients) == 0 {
+		fail("auth.clients: at least one client is required when auth is enabled " +
+			"(or set AUTH_BACKEND_KEY_SHA256, or auth.enabled: false to serve without api keys)")
+	}
+	names := make(map[string]bool)
+	for i, client := range c.Auth.Clients {
+		if client.Name == "" {
+			fail("auth.clients[%d].name: must not be empty", i)
+		} else if names[client.Name] {
+			fail("auth.clients[%d].name: duplicate client %q", i, client.Name)
+		}
+		names[client.Name] = true
+
+		if decoded, err := hex.DecodeString(client.KeySHA256); err != nil || len(decoded) != 32 {
+			fail("auth.clients[%d].key_sha256: must be a 64 character hex sha256 digest", i)
+		}
+		for _, ns := range client.Namespaces {
+			if !namespace.Valid(ns) {
+				fail("auth.clients[%d].namespaces: %q must be lowercase letters, digits, - and _ (at most 63)", i, ns)
+			}
+		}
+		if client.RatePerSecond < 0 || client.Burst < 0 || client.MaxConcurrentJobs < 0 {
+			fail("auth.clients[%d]: limits must not be negative", i)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ValidateStorage reports every invalid storage and manifest setting at once,
+// for tools that use nothing else
+func (c *Config) ValidateStorage() error {
+	var errs []error
+	c.validateStorage(func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	})
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *Config) validateStorage(fail func(format string, args ...any)) {
+	switch c.Storage.Backend {
+	case "azure":
+		if c.Storage.Azure.AccountName == "" || c.Storage.Azure.AccountKey == "" {
+			fail("storage.azure: account_name and account_key must not be empty")
+		}
+		if !strings.HasPrefix(c.Storage.Azure.BlobURL, "http://") && !strings.HasPrefix(c.Storage.Azure.BlobURL, "https://") {
+			fail("storage.azure.blob_url: must be an http(s) URL (got %q)", c.Storage.Azure.BlobURL)
+		}
+		if c.Storage.Azure.ContainerName == "" {
+			fail("storage.azure.container_name: must not be empty")
+		}
+		if url := c.Storage.Azure.PublicBlobURL; url != "" && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			fail("storage.azure.public_blob_url: must be an http(s) URL (got %q)", url)
+		}
+	case "filesystem":
+		if c.Storage.Filesystem.Root == "" {
+			fail("storage.filesystem.root: required when the backend is filesystem (or set STORAGE_FILESYSTEM_ROOT)")
+		}
+		if !strings.HasPrefix(c.Storage.Filesystem.BaseURL, "http://") && !strings.HasPrefix(c.Storage.Filesystem.BaseURL, "https://") {
+			fail("storage.filesystem.base_url: must be an http(s) URL (got %q)", c.Storage.Filesystem.BaseURL)
+		}
+	default:
+		fail("storage.backend: unsupported backend %q", c.Storage.Backend)
+	}
+
+	if c.Storage.KeepVersions < 0 {
+		fail("storage.keep_versions: must not be negative")
+	}
+
+	switch c.Manifest.Driver {
+	case "":
+	case "sqlite", "mysql":
+		if c.Manifest.DSN == "" {
+			fail("manifest.dsn: required when a manifest driver is set (or set MANIFEST_DSN)")
+		}
+	default:
+		fail("manifest.driver: must be sqlite, mysql or empty (got %q)", c.Manifest.Driver)
+	}
+}
+
+// ProviderEnabled reports whether name is a known provider that is switched on
+func (c *Config) ProviderEnabled(name string) bool {
+	switch name {
+	case "azure":
+		return c.Providers.Azure.Enabled
+	case "google":
+		return c.Providers.Google.Enabled
+	default:
+		return false
+	}
+}
+
+// EnabledProviders lists the providers that are switched on
+func (c *Config) EnabledProviders() []string {
+	var providers []string
+	for _, name := range []string{"azure", "google"} {
+		if c.ProviderEnabled(name) {
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
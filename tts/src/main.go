@@ -1,117 +1,425 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+	"tts/src/accounting"
+	"tts/src/apierror"
+	"tts/src/auth"
+	"tts/src/config"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/namespace"
+	"tts/src/retention"
+	"tts/src/signing"
 	"tts/src/storage"
+	"tts/src/synthesis"
 	"tts/src/tts"
+	"tts/src/webhook"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/gin-gonic/gin"
 )
 
 type ProcessRequest struct {
-	Engine string     `json:"engine"`
-	Gender string     `json:"gender"`
-	Words  []tts.Word `json:"words"`
+	Engine string     `json:"engine" binding:"omitempty,oneof=azure google"`
+	Gender string     `json:"gender" binding:"omitempty,oneof=male female any"`
+	Words  []tts.Word `json:"words" binding:"required,min=1,dive"`
+	// Async returns the job at once instead of waiting for it, so its progress
+	// can be followed at /jobs/:id/events. It counts against the client's
+	// concurrent jobs until it has finished.
+	Async bool `json:"async"`
+	// Callback POSTs the finished job to a URL and implies Async
+	Callback *Callback `json:"callback"`
+}
+
+type ProcessResponse struct {
+	JobID string `json:"job_id"`
+}
+
+type UsageResponse struct {
+	Usage   []accounting.Entry `json:"usage"`
+	Budgets accounting.Budgets `json:"budgets"`
 }
 
 type BatchAudioRequest struct {
 	IDs []string `json:"ids" binding:"required,min=1"`
 }
 
+// version identifies the build in clip metadata. Release builds set it with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// shutdownTimeout is how long requests in flight get to finish on SIGTERM
+const shutdownTimeout = 30 * time.Second
+
+var (
+	cfg      *config.Config
+	blobDB   storage.BlobDatabase
+	clips    *storage.ClipStore
+	manifest *storage.Manifest
+	engines  map[string]*synthesis.Engine
+	workers  *WorkerPool
+	jobs     = NewJobStore(jobHistory)
+	hooks    *webhook.Dispatcher
+)
+
 func main() {
-	engine, err := NewEngine("azure", nil)
+	var err error
+	cfg, err = config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	logging.Setup(cfg.LogLevel)
+
+	// Stopping the server lets main return, running the deferred shutdown of
+	// the workers, webhooks, gRPC server and manifest
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ledger, err := accounting.NewLedger(accounting.Budgets{
+		DailyCharacters:         cfg.Accounting.Budgets.DailyCharacters,
+		MonthlyCharacters:       cfg.Accounting.Budgets.MonthlyCharacters,
+		CallerDailyCharacters:   cfg.Accounting.Budgets.CallerDailyCharacters,
+		CallerMonthlyCharacters: cfg.Accounting.Budgets.CallerMonthlyCharacters,
+	}, cfg.Accounting.UsageFile)
+	if err != nil {
+		fatal("failed to load usage", err)
+	}
+	accounting.SetDefault(ledger)
+	// Deferred first so it runs last, once the workers have recorded their usage
+	defer func() {
+		if err := ledger.Flush(); err != nil {
+			slog.Error("failed to save usage", "error", err)
+		}
+	}()
+
+	blobDB, err = storage.OpenConfig(cfg.Storage)
 	if err != nil {
-		panic(err)
+		fatal("failed to connect to blob storage", err)
+	}
+
+	if cfg.Manifest.Driver != "" {
+		manifest, err = storage.OpenManifest(context.Background(), cfg.Manifest.Driver, cfg.Manifest.DSN)
+		if err != nil {
+			fatal("failed to open manifest", err, "driver", cfg.Manifest.Driver)
+		}
+		defer manifest.Close()
+	}
+
+	if cfg.SignedURLs.Enabled {
+		setupSignedURLs()
+	}
+
+	clips = storage.NewClipStore(blobDB, cfg.Storage.KeepVersions, manifest)
+
+	collector = retention.NewCollector(blobDB, manifest, retention.Options{
+		RatePerSecond: cfg.Retention.DeleteRatePerSecond,
+		BatchSize:     cfg.Retention.BatchSize,
+	})
+	if days := cfg.Retention.HistoryMaxAgeDays; days > 0 {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		go collector.Run(ctx, time.Duration(cfg.Retention.IntervalHours)*time.Hour,
+			retention.Policy{AllNamespaces: true, MaxAge: time.Duration(days) * 24 * time.Hour})
+	}
+
+	engines = make(map[string]*synthesis.Engine)
+	for _, provider := range cfg.EnabledProviders() {
+		engine, err := synthesis.NewEngine(provider, cfg, clips, synthesis.Options{Version: version, ClipURL: clipURL})
+		if err != nil {
+			fatal("failed to create engine", err, "provider", provider)
+		}
+		defer engine.Close()
+		engines[provider] = engine
+	}
+
+	hooks = webhook.NewDispatcher(webhook.Options{
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: time.Duration(cfg.Webhooks.InitialBackoffMs) * time.Millisecond,
+		Timeout:        time.Duration(cfg.Webhooks.TimeoutMs) * time.Millisecond,
+		LogSize:        jobHistory,
+
+		AllowedHosts:         cfg.Webhooks.AllowedHosts,
+		AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+	})
+	defer hooks.Close()
+
+	workers = NewWorkerPool(cfg.Workers.Count, cfg.Workers.QueueSize)
+	defer workers.Close()
+
+	var clients []auth.Client
+	for _, client := range cfg.Auth.Clients {
+		clients = append(clients, auth.Client{
+			Name:              client.Name,
+			KeyHash:           client.KeySHA256,
+			RatePerSecond:     client.RatePerSecond,
+			Burst:             client.Burst,
+			MaxConcurrentJobs: client.MaxConcurrentJobs,
+			Admin:             client.Admin,
+			CanDelete:         client.CanDelete,
+			Namespaces:        client.Namespaces,
+		})
+	}
+	authenticator, err := auth.NewAuthenticator(cfg.Auth.Enabled, clients)
+	if err != nil {
+		fatal("failed to configure auth", err)
+	}
+	if !cfg.Auth.Enabled {
+		slog.Warn("api key authentication is disabled, admin routes are not served")
+	}
+
+	if err := setupValidation(); err != nil {
+		fatal("failed to configure request validation", err)
+	}
+	spec, err := marshalOpenAPI()
+	if err != nil {
+		fatal("failed to build openapi document", err)
+	}
+
+	router := gin.New()
+	router.Use(gin.Recovery(), logging.Middleware())
+
+	router.GET("/api/v1/health", handleHealthRequest)
+	router.GET("/api/v1/ready", handleReadyRequest)
+	router.GET("/api/v1/openapi.json", handleOpenAPIRequest(spec))
+	// Metrics carry per-namespace labels, so they need an admin key once there are keys
+	if authenticator.Enabled() {
+		router.GET("/metrics", authenticator.Middleware(), auth.RequireAdmin(), metrics.Handler())
+	} else {
+		router.GET("/metrics", metrics.Handler())
+	}
+	if clipSigner != nil {
+		router.GET(signing.Route+"*name", handleSignedClipRequest)
+		router.HEAD(signing.Route+"*name", handleSignedClipRequest)
+	}
+
+	api := router.Group("/api/v1", authenticator.Middleware())
+	api.POST("/process/word", authenticator.LimitJobs(), handleProcessRequest)
+	api.POST("/process/sentence", authenticator.LimitJobs(), handleProcessRequest)
+	api.POST("/preview", authenticator.LimitJobs(), handlePreviewRequest)
+	api.POST("/preview/stream", authenticator.LimitJobs(), handlePreviewStreamRequest)
+	api.GET("/get/:id/word", handleGetRequest)
+	api.GET("/get/:id/sentence", handleGetRequest)
+	api.HEAD("/get/:id/word", handleGetRequest)
+	api.HEAD("/get/:id/sentence", handleGetRequest)
+	api.GET("/audio/:id/word/history", handleHistoryRequest)
+	api.GET("/audio/:id/sentence/history", handleHistoryRequest)
+	api.POST("/audio/:id/word/rollback", handleRollbackRequest)
+	api.POST("/audio/:id/sentence/rollback", handleRollbackRequest)
+	api.GET("/audio/:id/word/url", handleClipURLRequest)
+	api.GET("/audio/:id/sentence/url", handleClipURLRequest)
+	api.POST("/audio/missing/word", handleMissingAudioRequest)
+	api.POST("/audio/missing/sentence", handleMissingAudioRequest)
+	api.POST("/regenerate/word", authenticator.LimitJobs(), handleRegenerateRequest)
+	api.POST("/regenerate/sentence", authenticator.LimitJobs(), handleRegenerateRequest)
+	api.POST("/reconcile/word", authenticator.LimitJobs(), handleReconcileRequest)
+	api.POST("/reconcile/sentence", authenticator.LimitJobs(), handleReconcileRequest)
+	api.POST("/anki/media", handleMediaBundleRequest)
+	api.GET("/jobs/:id", handleJobRequest)
+	api.GET("/jobs/:id/delivery", handleJobDeliveryRequest)
+	api.GET("/jobs/:id/events", handleJobEventsRequest)
+	api.GET("/usage", handleUsageRequest)
+	if authenticator.Enabled() {
+		api.DELETE("/audio/:id/word", auth.RequireDelete(), handleDeleteRequest)
+		api.DELETE("/audio/:id/sentence", auth.RequireDelete(), handleDeleteRequest)
+		api.POST("/admin/retention", auth.RequireAdmin(), handleRetentionRequest)
+	}
+
+	if cfg.GRPC.Listen != "" {
+		listener, err := net.Listen("tcp", cfg.GRPC.Listen)
+		if err != nil {
+			fatal("failed to listen for grpc", err, "addr", cfg.GRPC.Listen)
+		}
+		grpcServer := newGRPCServer(authenticator)
+		defer grpcServer.GracefulStop()
+		go func() {
+			slog.Info("starting grpc server", "addr", cfg.GRPC.Listen)
+			if err := grpcServer.Serve(listener); err != nil {
+				fatal("grpc server stopped", err)
+			}
+		}()
+	}
+
+	server := &http.Server{Addr: cfg.Listen, Handler: router}
+	go func() {
+		slog.Info("starting server", "addr", cfg.Listen, "providers", cfg.EnabledProviders())
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server stopped", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to finish requests in flight", "error", err)
+	}
+}
+
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
+}
+
+// engineFor returns the engine for a requested provider, using the default provider when none is given
+func engineFor(name string) (*synthesis.Engine, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		name = cfg.DefaultProvider
 	}
-	defer engine.Close()
 
-	router := gin.Default()
+	engine, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("engine %q is not enabled", name)
+	}
+	return engine, nil
+}
 
-	router.POST("/api/v1/process/word", handleProcessRequest)
-	router.POST("/api/v1/process/sentence", handleProcessRequest)
-	router.GET("/api/v1/get/:id/word", handleGetRequest)
-	router.GET("/api/v1/get/:id/sentence", handleGetRequest)
+// engineParam resolves the engine named in a request, answering 400 when it is not enabled
+func engineParam(c *gin.Context, name string) (*synthesis.Engine, bool) {
+	engine, err := engineFor(name)
+	if err != nil {
+		metrics.Error(metrics.ErrorInvalidRequest)
+		apierror.Abort(c, http.StatusBadRequest, apierror.EngineDisabled, err.Error())
+		return nil, false
+	}
+	return engine, true
+}
 
-	fmt.Println("[TTS-debug] Starting server")
-	router.Run(":8081")
+// voiceParam checks a voice named in a request, answering 400 when the engine
+// is not configured with it
+func voiceParam(c *gin.Context, engine *synthesis.Engine, voice string) bool {
+	if err := engine.CheckVoice(voice); err != nil {
+		metrics.Error(metrics.ErrorInvalidRequest)
+		apierror.Abort(c, http.StatusBadRequest, apierror.UnknownVoice, err.Error())
+		return false
+	}
+	return true
 }
 
 func handleProcessRequest(c *gin.Context) {
-	isSentenceReq := strings.Contains(c.Request.URL.Path, "sentence")
 	var req ProcessRequest
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &req) {
 		return
 	}
+	if req.Gender == "" {
+		req.Gender = "any"
+	}
 
-	var engine *Engine
-	var err error
-	switch strings.ToLower(req.Engine) {
-	case "google":
-		engine, err = NewEngine("google", nil)
-	case "azure":
-		engine, err = NewEngine("azure", nil)
-	default:
-		engine, err = NewEngine("azure", nil)
+	engine, ok := engineParam(c, req.Engine)
+	if !ok {
+		return
 	}
-	if err != nil {
-		panic(err)
+
+	if req.Callback != nil {
+		if err := hooks.CheckURL(req.Callback.URL); err != nil {
+			metrics.Error(metrics.ErrorInvalidRequest)
+			apierror.Abort(c, http.StatusBadRequest, apierror.ValidationFailed, err.Error())
+			return
+		}
+	}
+
+	if req.Async || req.Callback != nil {
+		// The job outlives the request, keeping its logger and caller
+		ctx := context.WithoutCancel(c.Request.Context())
+		job, wait, err := startBatchJob(ctx, engine, req.Words, req.Gender, isSentenceRoute(c))
+		if err != nil {
+			writeSynthesisError(c, err)
+			return
+		}
+		go func() {
+			job, _ := wait()
+			if req.Callback != nil {
+				notifyJob(ctx, job, req.Callback)
+			}
+		}()
+		c.JSON(http.StatusAccepted, ProcessResponse{JobID: job.ID})
+		return
 	}
-	defer engine.Close()
 
-	_, err = engine.BatchProcessWords(req.Words, req.Gender, isSentenceReq)
+	job, err := processWords(c.Request.Context(), engine, req.Words, req.Gender, isSentenceRoute(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeSynthesisError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{})
+	c.JSON(http.StatusCreated, ProcessResponse{JobID: job.ID})
 }
 
-func handleGetRequest(c *gin.Context) {
-	isSentenceReq := strings.Contains(c.Request.URL.Path, "sentence")
-	// Get ID from URL path parameter instead of JSON body
-	id := c.Param("id")
+// audioCacheControl lets clients reuse a clip for an hour and revalidate it
+// cheaply with its ETag afterwards, since regenerated clips keep their URL
+const audioCacheControl = "public, max-age=3600, must-revalidate"
 
-	// Connect to db
-	blobDB, err := storage.ConnectToAzurite()
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to retrieve audio: %v", err),
-		})
+// handleGetRequest serves a stored clip straight from blob storage, supporting
+// Range requests and If-None-Match / If-Modified-Since revalidation
+func handleGetRequest(c *gin.Context) {
+	path, ok := clipPathParam(c)
+	if !ok {
+		return
 	}
 
-	// Get the audio data
-	audioData, err := blobDB.GetTTSAudio(id, isSentenceReq)
+	c.Header("Cache-Control", audioCacheControl)
+	// The same URL serves a different clip in each namespace
+	c.Header("Vary", namespace.Header)
+	serveClip(c, path, c.Param("id")+".wav")
+}
+
+// serveClip writes the blob at path as an audio download named filename
+func serveClip(c *gin.Context, path, filename string) {
+	audio, err := blobDB.OpenTTSAudio(c.Request.Context(), path)
 	if err != nil {
-		if isNotFoundError(err) { // Implement this error check
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Audio file not found"})
+		if storage.IsNotFound(err) {
+			apierror.Abort(c, http.StatusNotFound, apierror.NotFound, "Audio file not found")
 			return
 		}
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to retrieve audio: %v", err),
-		})
+		metrics.Error(metrics.ErrorDownload)
+		apierror.Abort(c, http.StatusInternalServerError, apierror.StorageFailed,
+			fmt.Sprintf("Failed to retrieve audio: %v", err))
 		return
 	}
+	defer audio.Close()
 
-	// Set headers for file download
+	props := audio.Properties()
+	if etag := props.ETag; etag != "" {
+		if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = `"` + etag + `"`
+		}
+		c.Header("ETag", etag)
+	}
 	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.wav", id))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 	c.Header("Content-Type", "audio/wav")
-	c.Header("Content-Length", fmt.Sprintf("%d", len(audioData)))
 
-	// Send the binary data
-	c.Data(http.StatusOK, "audio/wav", audioData)
+	http.ServeContent(c.Writer, c.Request, filename, props.LastModified, audio)
 }
 
-func isNotFoundError(err error) bool {
-	var respErr *azcore.ResponseError
-	if errors.As(err, &respErr) {
-		return respErr.StatusCode == http.StatusNotFound
+func handleUsageRequest(c *gin.Context) {
+	ledger := accounting.Default()
+
+	// Only admins may look at other callers' usage
+	caller := c.Query("caller")
+	if client := auth.ClientFromGin(c); !client.Admin {
+		caller = client.Name
 	}
-	return false
+
+	entries, err := ledger.Report(c.DefaultQuery("period", "daily"), c.Query("provider"), caller)
+	if err != nil {
+		apierror.Abort(c, http.StatusBadRequest, apierror.ValidationFailed, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, UsageResponse{Usage: entries, Budgets: ledger.Budgets()})
 }
@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Error classes recorded by ErrorsTotal
+const (
+	ErrorProviderNetwork = "provider_network"
+	ErrorProviderAuth    = "provider_auth"
+	ErrorProviderClient  = "provider_4xx"
+	ErrorProviderServer  = "provider_5xx"
+	ErrorProviderDecode  = "provider_decode"
+	ErrorSplit           = "split"
+	ErrorUpload          = "upload"
+	ErrorDownload        = "download"
+	ErrorInvalidRequest  = "invalid_request"
+)
+
+var (
+	ProviderLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tts",
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of synthesis requests sent to a TTS provider.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 4, 8, 16, 32, 64},
+	}, []string{"provider", "voice", "status"})
+
+	ProviderFirstByte = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tts",
+		Name:      "provider_first_byte_seconds",
+		Help:      "Time until a streaming TTS provider starts returning audio.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 4, 8},
+	}, []string{"provider", "voice"})
+
+	ProviderCharacters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "provider_characters_total",
+		Help:      "Characters of SSML sent to a TTS provider.",
+	}, []string{"provider", "voice"})
+
+	SplitMismatches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "split_mismatch_total",
+		Help:      "Batches where silence splitting produced a different number of chunks than words.",
+	}, []string{"provider", "direction"})
+
+	SplitChunks = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tts",
+		Name:      "split_chunk_delta",
+		Help:      "Difference between produced chunks and requested words per batch.",
+		Buckets:   []float64{-10, -5, -2, -1, 0, 1, 2, 5, 10},
+	}, []string{"provider"})
+
+	StorageLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "tts",
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of blob storage operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "status"})
+
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups by cache name and result.",
+	}, []string{"cache", "result"})
+
+	WebhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "webhook_attempts_total",
+		Help:      "Webhook delivery attempts by outcome.",
+	}, []string{"result"})
+
+	RetentionDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "retention_deleted_blobs_total",
+		Help:      "Blobs removed by retention sweeps by reason and outcome.",
+	}, []string{"reason", "status"})
+
+	ClipsStored = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "clips_stored_total",
+		Help:      "Clips written to blob storage by namespace and kind.",
+	}, []string{"namespace", "kind"})
+
+	NamespaceRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "namespace_requests_total",
+		Help:      "API requests by namespace, route and status code.",
+	}, []string{"namespace", "route", "status"})
+
+	ErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "tts",
+		Name:      "errors_total",
+		Help:      "Errors by class.",
+	}, []string{"class"})
+)
+
+// ObserveProvider records the latency and outcome of a single provider call
+func ObserveProvider(provider, voice string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	ProviderLatency.WithLabelValues(provider, voice, status).Observe(time.Since(start).Seconds())
+}
+
+// ObserveSplit records how far the splitter was from the expected chunk count
+func ObserveSplit(provider string, chunks, words int) {
+	delta := chunks - words
+	SplitChunks.WithLabelValues(provider).Observe(float64(delta))
+
+	switch {
+	case delta > 0:
+		SplitMismatches.WithLabelValues(provider, "too_many").Inc()
+	case delta < 0:
+		SplitMismatches.WithLabelValues(provider, "too_few").Inc()
+	}
+}
+
+// ObserveStorage records the latency and outcome of a blob storage operation
+func ObserveStorage(operation string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	StorageLatency.WithLabelValues(operation, status).Observe(time.Since(start).Seconds())
+}
+
+// CacheHit records a cache lookup
+func CacheHit(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// WebhookAttempt records one webhook delivery attempt: delivered, retry or failed
+func WebhookAttempt(result string) {
+	WebhookAttempts.WithLabelValues(result).Inc()
+}
+
+// RetentionDelete records a blob a retention sweep tried to delete
+func RetentionDelete(reason string, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	RetentionDeleted.WithLabelValues(reason, status).Inc()
+}
+
+// ClipStored records a clip written to blob storage
+func ClipStored(namespace, kind string) {
+	ClipsStored.WithLabelValues(namespace, kind).Inc()
+}
+
+// NamespaceRequest records an API request made in a namespace
+func NamespaceRequest(namespace, route string, status int) {
+	NamespaceRequests.WithLabelValues(namespace, route, strconv.Itoa(status)).Inc()
+}
+
+// Error increments the error counter for the given class
+func Error(class string) {
+	ErrorsTotal.WithLabelValues(class).Inc()
+}
+
+// ProviderStatusError maps a provider HTTP status code to an error class
+func ProviderStatusError(statusCode int) string {
+	switch {
+	case statusCode == 401 || statusCode == 403:
+		return ErrorProviderAuth
+	case statusCode >= 500:
+		return ErrorProviderServer
+	default:
+		return ErrorProviderClient
+	}
+}
+
+// Handler exposes the default registry for scraping
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
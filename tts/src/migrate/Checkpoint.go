@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// syncEvery bounds how many copied blobs a crash can forget
+const syncEvery = 100
+
+// Entry records one copied blob. Sizes and checksums are of the blob as read
+// from the source and as written to the destination.
+type Entry struct {
+	Source     string `json:"source"`
+	SourceSize int64  `json:"source_size"`
+	// SourceModified is when the source blob was last written, as listed. A
+	// blob rewritten since it was copied keeps its size when clips are of the
+	// same length, so only this shows it changed.
+	SourceModified time.Time `json:"source_modified"`
+	SourceSHA256   string    `json:"source_sha256"`
+	Dest           string    `json:"dest"`
+	DestSHA256     string    `json:"dest_sha256"`
+}
+
+// Checkpoint is an append-only file of JSON lines, one Entry per copied blob.
+// A later line for the same source replaces earlier ones.
+type Checkpoint struct {
+	mu      sync.Mutex
+	entries map[string]Entry
+	file    *os.File
+	pending int
+}
+
+// OpenCheckpoint loads the entries at path. With writable set new entries are
+// appended to it, creating the file if needed. An empty path keeps nothing.
+func OpenCheckpoint(path string, writable bool) (*Checkpoint, error) {
+	c := &Checkpoint{entries: make(map[string]Entry)}
+	if path == "" {
+		return c, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+	}
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var entry Entry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				// A crash mid write leaves a torn last line, which is copied again
+				continue
+			}
+			c.entries[entry.Source] = entry
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+		}
+	}
+
+	if writable {
+		c.file, err = os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open checkpoint: %w", err)
+		}
+		// End a torn last line so the next entry starts on its own
+		if info, err := c.file.Stat(); err == nil && info.Size() > 0 {
+			last := make([]byte, 1)
+			if _, err := c.file.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+				c.file.Write([]byte{'\n'})
+			}
+		}
+	}
+	return c, nil
+}
+
+// Get returns the entry of a copied source blob
+func (c *Checkpoint) Get(source string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[source]
+	return entry, ok
+}
+
+// Entries returns every entry ordered by source name
+func (c *Checkpoint) Entries() []Entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make([]Entry, 0, len(c.entries))
+	for _, entry := range c.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Source < entries[j].Source })
+	return entries
+}
+
+// Add records a copied blob
+func (c *Checkpoint) Add(entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.Source] = entry
+	if c.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if c.pending++; c.pending >= syncEvery {
+		c.pending = 0
+		return c.file.Sync()
+	}
+	return nil
+}
+
+func (c *Checkpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := errors.Join(c.file.Sync(), c.file.Close())
+	c.file = nil
+	return err
+}
@@ -0,0 +1,292 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"tts/src/storage"
+)
+
+// Rewriter maps the name of a source blob to its name in the destination
+type Rewriter func(name string) string
+
+// ReplacePrefix moves blobs under from to under to, such as "tts/" to
+// "tenants/acme/tts/". Other names are left alone.
+func ReplacePrefix(from, to string) Rewriter {
+	return func(name string) string {
+		if strings.HasPrefix(name, from) {
+			return to + strings.TrimPrefix(name, from)
+		}
+		return name
+	}
+}
+
+// contentAddressedName names a blob whose content has the SHA-256 sum under
+// prefix, as prefix/ab/abcdef...wav
+func contentAddressedName(prefix, name, sum string) string {
+	return prefix + sum[:2] + "/" + sum + path.Ext(name)
+}
+
+type Options struct {
+	// Prefix selects the blobs to copy
+	Prefix string
+	// Rewrite is applied in order to every name
+	Rewrite []Rewriter
+	// ContentAddressed, when set, names every blob after the SHA-256 of its
+	// content under this prefix, after any Rewrite. The checkpoint is then the
+	// only index from the original names. Each blob is read twice, first to
+	// hash it.
+	ContentAddressed string
+	// Transcoder converts .wav blobs, nil copies them as they are. Blobs are
+	// transcoded in memory; all others are streamed.
+	Transcoder *Transcoder
+	// Checkpoint is the file recording copied blobs. Blobs it lists with an
+	// unchanged size and modification time are skipped, so an interrupted
+	// migration resumes.
+	Checkpoint string
+	// Parallelism is how many blobs are copied at once
+	Parallelism int
+	// DryRun reads and converts every blob but writes nothing
+	DryRun bool
+}
+
+type Report struct {
+	Listed  int
+	Copied  int
+	Skipped int
+	Failed  int
+	Bytes   int64
+}
+
+// Copy streams every blob under opts.Prefix from one store to another, one
+// blob at a time per worker. Blobs that fail are logged and counted, and are
+// retried by running Copy again with the same checkpoint.
+func Copy(ctx context.Context, from, to storage.BlobDatabase, opts Options) (Report, error) {
+	if opts.Parallelism < 1 {
+		opts.Parallelism = 1
+	}
+
+	checkpoint, err := OpenCheckpoint(opts.Checkpoint, !opts.DryRun)
+	if err != nil {
+		return Report{}, err
+	}
+	defer checkpoint.Close()
+
+	items, err := from.ListBlobs(ctx, opts.Prefix)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to list source: %w", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		report = Report{Listed: len(items)}
+		wg     sync.WaitGroup
+		slots  = make(chan struct{}, opts.Parallelism)
+	)
+	for _, item := range items {
+		if done, ok := checkpoint.Get(item.Name); ok && done.SourceSize == item.Size && done.SourceModified.Equal(item.LastModified) {
+			report.Skipped++
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+
+			entry, err := copyBlob(ctx, from, to, item, opts)
+			entry.SourceModified = item.LastModified
+			if err == nil && !opts.DryRun {
+				err = checkpoint.Add(entry)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				report.Failed++
+				slog.Warn("failed to copy blob", "blob", item.Name, "error", err)
+				return
+			}
+			report.Copied++
+			report.Bytes += item.Size
+			if report.Copied%1000 == 0 {
+				slog.Info("migration progress", "copied", report.Copied, "skipped", report.Skipped,
+					"failed", report.Failed, "listed", report.Listed)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return report, ctx.Err()
+}
+
+func copyBlob(ctx context.Context, from, to storage.BlobDatabase, item storage.BlobItem, opts Options) (Entry, error) {
+	if opts.Transcoder != nil && path.Ext(item.Name) == ".wav" {
+		return transcodeBlob(ctx, from, to, item, opts)
+	}
+
+	name := item.Name
+	for _, rewrite := range opts.Rewrite {
+		name = rewrite(name)
+	}
+	var addressed string
+	if opts.ContentAddressed != "" {
+		_, sum, err := hashBlob(ctx, from, item.Name)
+		if err != nil {
+			return Entry{}, err
+		}
+		name, addressed = contentAddressedName(opts.ContentAddressed, name, sum), sum
+	}
+
+	source, err := from.OpenTTSAudio(ctx, item.Name)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer source.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	reader := io.TeeReader(source, io.MultiWriter(hash, counter))
+	if opts.DryRun {
+		_, err = io.Copy(io.Discard, reader)
+	} else {
+		err = to.WriteBlobFrom(ctx, name, reader, storage.ContentType(name))
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if addressed != "" && sum != addressed {
+		return Entry{}, fmt.Errorf("%s changed while it was copied", item.Name)
+	}
+	return Entry{Source: item.Name, SourceSize: counter.n, SourceSHA256: sum, Dest: name, DestSHA256: sum}, nil
+}
+
+// transcodeBlob copies a .wav blob converted by opts.Transcoder
+func transcodeBlob(ctx context.Context, from, to storage.BlobDatabase, item storage.BlobItem, opts Options) (Entry, error) {
+	data, err := from.ReadBlob(ctx, item.Name)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{Source: item.Name, SourceSize: int64(len(data)), SourceSHA256: sha256Hex(data)}
+
+	data, err = opts.Transcoder.Transcode(ctx, data)
+	if err != nil {
+		return Entry{}, err
+	}
+	name := strings.TrimSuffix(item.Name, ".wav") + opts.Transcoder.Ext()
+	for _, rewrite := range opts.Rewrite {
+		name = rewrite(name)
+	}
+	entry.DestSHA256 = sha256Hex(data)
+	if opts.ContentAddressed != "" {
+		name = contentAddressedName(opts.ContentAddressed, name, entry.DestSHA256)
+	}
+	entry.Dest = name
+
+	if opts.DryRun {
+		return entry, nil
+	}
+	if err := to.WriteBlob(ctx, name, data, storage.ContentType(name)); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// hashBlob reads a blob through, returning its size and hex SHA-256
+func hashBlob(ctx context.Context, db storage.BlobDatabase, name string) (int64, string, error) {
+	blob, err := db.OpenTTSAudio(ctx, name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer blob.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, blob)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+type VerifyReport struct {
+	Checked    int
+	Missing    []string
+	Mismatched []string
+}
+
+// Verify reads every blob the checkpoint lists from the destination and
+// compares it with the checksum recorded when it was copied
+func Verify(ctx context.Context, to storage.BlobDatabase, checkpointPath string, parallelism int) (VerifyReport, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	checkpoint, err := OpenCheckpoint(checkpointPath, false)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	defer checkpoint.Close()
+
+	var (
+		mu     sync.Mutex
+		report VerifyReport
+		wg     sync.WaitGroup
+		slots  = make(chan struct{}, parallelism)
+		failed error
+	)
+	for _, entry := range checkpoint.Entries() {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+
+			_, sum, err := hashBlob(ctx, to, entry.Dest)
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case storage.IsNotFound(err):
+				report.Missing = append(report.Missing, entry.Dest)
+			case err != nil:
+				failed = err
+			case sum != entry.DestSHA256:
+				report.Mismatched = append(report.Mismatched, entry.Dest)
+			}
+			report.Checked++
+		}()
+	}
+	wg.Wait()
+
+	if failed != nil {
+		return report, fmt.Errorf("failed to read destination: %w", failed)
+	}
+	return report, ctx.Err()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
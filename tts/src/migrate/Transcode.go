@@ -0,0 +1,75 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// formats are the targets ffmpeg converts clips to, by name
+var formats = map[string]struct {
+	ext  string
+	args []string
+}{
+	"mp3":  {".mp3", []string{"-f", "mp3", "-codec:a", "libmp3lame", "-q:a", "4"}},
+	"ogg":  {".ogg", []string{"-f", "ogg", "-codec:a", "libopus", "-b:a", "48k"}},
+	"flac": {".flac", []string{"-f", "flac", "-codec:a", "flac"}},
+}
+
+// Formats lists the names Transcoder accepts
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Transcoder converts WAV clips to another format with ffmpeg
+type Transcoder struct {
+	ffmpeg string
+	format string
+}
+
+// NewTranscoder converts to format with the ffmpeg binary at path, or at
+// FFMPEG_PATH or on the PATH when empty
+func NewTranscoder(path, format string) (*Transcoder, error) {
+	if _, ok := formats[format]; !ok {
+		return nil, fmt.Errorf("unsupported format %q, expected one of %s", format, strings.Join(Formats(), ", "))
+	}
+	if path == "" {
+		path = os.Getenv("FFMPEG_PATH")
+	}
+	if path == "" {
+		path = "ffmpeg"
+	}
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	return &Transcoder{ffmpeg: resolved, format: format}, nil
+}
+
+// Ext is the extension of converted clips
+func (t *Transcoder) Ext() string {
+	return formats[t.format].ext
+}
+
+func (t *Transcoder) Transcode(ctx context.Context, wav []byte) ([]byte, error) {
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-f", "wav", "-i", "pipe:0"}, formats[t.format].args...)
+	cmd := exec.CommandContext(ctx, t.ffmpeg, append(args, "pipe:1")...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(wav)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to transcode to %s: %w: %s", t.format, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
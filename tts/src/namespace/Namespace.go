@@ -0,0 +1,47 @@
+package namespace
+
+import (
+	"context"
+	"regexp"
+)
+
+// Header names the namespace of an HTTP request, and MetadataKey that of a gRPC call
+const (
+	Header      = "X-TTS-Namespace"
+	MetadataKey = "x-tts-namespace"
+)
+
+// Default is the namespace of requests that name none. Its clips keep the
+// original tts/word/ and tts/sentence/ keys.
+const Default = ""
+
+// pattern keeps namespaces usable as a blob key segment and a metric label
+var pattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// Valid reports whether ns may be used as a namespace
+func Valid(ns string) bool {
+	return ns == Default || pattern.MatchString(ns)
+}
+
+// Label is ns as shown in logs and metrics, where the default needs a name
+func Label(ns string) string {
+	if ns == Default {
+		return "default"
+	}
+	return ns
+}
+
+type contextKey struct{}
+
+// With records the namespace a request works in on ctx
+func With(ctx context.Context, ns string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ns)
+}
+
+// FromContext returns the namespace of a request, or Default when there is none
+func FromContext(ctx context.Context) string {
+	if ns, ok := ctx.Value(contextKey{}).(string); ok {
+		return ns
+	}
+	return Default
+}
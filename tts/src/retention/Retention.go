@@ -0,0 +1,216 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/storage"
+)
+
+// Reasons a blob is collected
+const (
+	ReasonOrphaned = "orphaned"
+	ReasonExpired  = "expired"
+)
+
+// orphanGrace protects clips written after the backend took its list of live
+// ids, so a word created during a sweep does not lose its fresh audio
+const orphanGrace = time.Hour
+
+// Policy decides which blobs are garbage
+type Policy struct {
+	// Namespace is the namespace swept
+	Namespace string
+	// AllNamespaces sweeps every namespace instead, for policies without live ids
+	AllNamespaces bool
+	// Live holds the context ids of Namespace that still exist in the backend
+	// by kind ("word" or "sentence"). Every blob of an id missing from its
+	// kind's set is an orphan. Kinds without a set are not checked for orphans.
+	Live map[string]map[int]bool
+	// MaxAge expires archived versions older than this, zero keeps them.
+	// Current clips never expire, reconciliation would only synthesize them again.
+	MaxAge time.Duration
+}
+
+// Candidate is a blob the policy collects
+type Candidate struct {
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Reason       string    `json:"reason"`
+}
+
+// Report is the outcome of a sweep. A dry run leaves Deleted and Failed at zero.
+type Report struct {
+	DryRun     bool        `json:"dry_run"`
+	Scanned    int         `json:"scanned"`
+	Candidates []Candidate `json:"candidates"`
+	Bytes      int64       `json:"bytes"`
+	Deleted    int         `json:"deleted"`
+	Failed     int         `json:"failed"`
+}
+
+type Options struct {
+	// RatePerSecond caps deletes so a sweep does not crowd out synthesis uploads
+	RatePerSecond float64
+	// BatchSize is how many blobs are deleted between progress logs and manifest updates
+	BatchSize int
+}
+
+// clipKind groups the ids of one kind of clip in one namespace
+type clipKind struct {
+	namespace string
+	kind      string
+}
+
+// Collector finds and deletes blobs that a Policy no longer keeps
+type Collector struct {
+	db       storage.BlobDatabase
+	manifest *storage.Manifest
+	opts     Options
+	now      func() time.Time
+}
+
+// NewCollector returns a collector deleting from db. Records of orphaned ids are
+// removed from manifest too when it is not nil.
+func NewCollector(db storage.BlobDatabase, manifest *storage.Manifest, opts Options) *Collector {
+	if opts.RatePerSecond <= 0 {
+		opts.RatePerSecond = 20
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &Collector{db: db, manifest: manifest, opts: opts, now: time.Now}
+}
+
+// Plan lists every clip blob and returns those policy collects, by name
+func (c *Collector) Plan(ctx context.Context, policy Policy) (Report, error) {
+	prefix := storage.Prefix(policy.Namespace)
+	if policy.AllNamespaces {
+		prefix = ""
+	}
+	items, err := c.db.ListBlobs(ctx, prefix)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	now := c.now()
+	report := Report{DryRun: true, Scanned: len(items), Candidates: []Candidate{}}
+	for _, item := range items {
+		reason := c.reason(policy, item, now)
+		if reason == "" {
+			continue
+		}
+		report.Candidates = append(report.Candidates, Candidate{
+			Name:         item.Name,
+			Size:         item.Size,
+			LastModified: item.LastModified,
+			Reason:       reason,
+		})
+		report.Bytes += item.Size
+	}
+	sort.Slice(report.Candidates, func(i, j int) bool { return report.Candidates[i].Name < report.Candidates[j].Name })
+	return report, nil
+}
+
+// Sweep deletes what Plan finds, at most RatePerSecond blobs a second. Blobs
+// that fail to delete are counted and left for the next sweep.
+func (c *Collector) Sweep(ctx context.Context, policy Policy) (Report, error) {
+	report, err := c.Plan(ctx, policy)
+	if err != nil {
+		return report, err
+	}
+	report.DryRun = false
+	logger := logging.FromContext(ctx)
+
+	pace := time.NewTicker(time.Duration(float64(time.Second) / c.opts.RatePerSecond))
+	defer pace.Stop()
+
+	for start := 0; start < len(report.Candidates); start += c.opts.BatchSize {
+		batch := report.Candidates[start:min(start+c.opts.BatchSize, len(report.Candidates))]
+
+		forget := make(map[clipKind][]int)
+		for _, candidate := range batch {
+			select {
+			case <-pace.C:
+			case <-ctx.Done():
+				return report, ctx.Err()
+			}
+
+			err := c.db.DeleteTTSAudio(ctx, candidate.Name)
+			if errors.Is(err, storage.ErrNotFound) {
+				err = nil
+			}
+			metrics.RetentionDelete(candidate.Reason, err)
+			if err != nil {
+				report.Failed++
+				logger.Warn("failed to delete blob", "blob", candidate.Name, "error", err)
+				continue
+			}
+			report.Deleted++
+
+			// The manifest forgets an orphan with its clip, sidecars and versions hold no records
+			if ns, kind, id, ok := storage.ParseClipPath(candidate.Name); ok && candidate.Reason == ReasonOrphaned {
+				forget[clipKind{ns, kind}] = append(forget[clipKind{ns, kind}], id)
+			}
+		}
+
+		if c.manifest != nil {
+			for clips, ids := range forget {
+				if err := c.manifest.Forget(ctx, clips.namespace, clips.kind, ids); err != nil {
+					logger.Warn("manifest is out of date", "error", err)
+				}
+			}
+		}
+		logger.Info("retention sweep progress", "deleted", report.Deleted, "failed", report.Failed,
+			"remaining", len(report.Candidates)-start-len(batch))
+	}
+	return report, nil
+}
+
+// Run sweeps with policy every interval until ctx is done
+func (c *Collector) Run(ctx context.Context, interval time.Duration, policy Policy) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := c.Sweep(ctx, policy)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("retention sweep failed", "error", err)
+		} else if err == nil {
+			slog.Info("retention sweep finished", "scanned", report.Scanned, "deleted", report.Deleted,
+				"failed", report.Failed, "bytes", report.Bytes)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reason returns why policy collects item, or "" when it is kept
+func (c *Collector) reason(policy Policy, item storage.BlobItem, now time.Time) string {
+	path, archived, ok := storage.ClipOfBlob(item.Name)
+	if !ok {
+		return ""
+	}
+	ns, kind, id, ok := storage.ParseClipPath(path)
+	if !ok || (ns != policy.Namespace && !policy.AllNamespaces) {
+		return ""
+	}
+
+	if live, checked := policy.Live[kind]; checked && ns == policy.Namespace && !live[id] && now.Sub(item.LastModified) > orphanGrace {
+		return ReasonOrphaned
+	}
+	if archived && policy.MaxAge > 0 && now.Sub(item.LastModified) > policy.MaxAge {
+		return ReasonExpired
+	}
+	return ""
+}
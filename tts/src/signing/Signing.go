@@ -0,0 +1,72 @@
+package signing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route is where the service serves signed clips, followed by the blob name
+const Route = "/api/v1/clips/"
+
+// Query parameters of a signed URL
+const (
+	ParamExpires   = "expires"
+	ParamSignature = "sig"
+)
+
+var (
+	ErrExpired          = errors.New("signed url has expired")
+	ErrInvalidSignature = errors.New("signed url signature is invalid")
+)
+
+// Signer issues and checks URLs to clips served by this service, for backends
+// that cannot sign URLs themselves. The signature is the hex HMAC-SHA256 of
+// "<blob name>\n<expiry unix time>", so a URL opens one blob until it expires.
+type Signer struct {
+	secret  []byte
+	baseURL string
+}
+
+// NewSigner returns a signer for URLs under baseURL, the address clients
+// reach this service at
+func NewSigner(secret, baseURL string) *Signer {
+	return &Signer{secret: []byte(secret), baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// SignedURL returns a URL to the blob name that stops working at expires
+func (s *Signer) SignedURL(ctx context.Context, name string, expires time.Time) (string, error) {
+	query := url.Values{}
+	query.Set(ParamExpires, strconv.FormatInt(expires.Unix(), 10))
+	query.Set(ParamSignature, s.sign(name, expires.Unix()))
+	return s.baseURL + (&url.URL{Path: Route + name}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// Verify checks the expires and sig parameters of a URL to the blob name at now
+func (s *Signer) Verify(name, expires, signature string, now time.Time) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(name, unix))) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() >= unix {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) sign(name string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(name))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"tts/src/metrics"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobProperties describes a stored clip without downloading it
+type BlobProperties struct {
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	ContentType  string
+}
+
+// AudioBlob reads a stored clip on demand. Seeking is free; the next Read
+// downloads from the new offset.
+type AudioBlob interface {
+	io.ReadSeekCloser
+	Properties() BlobProperties
+}
+
+// IsNotFound reports whether err means the requested blob does not exist
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+// OpenTTSAudio returns a reader over a stored clip that fetches byte ranges as they are read
+func (db *AzureBlobDatabase) OpenTTSAudio(ctx context.Context, filename string) (AudioBlob, error) {
+	start := time.Now()
+	props, err := db.serviceClient.ServiceClient().
+		NewContainerClient(db.containerName).
+		NewBlobClient(filename).
+		GetProperties(ctx, nil)
+	metrics.ObserveStorage("stat", start, err)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, filename)
+		}
+		return nil, fmt.Errorf("failed to read audio properties: %w", err)
+	}
+
+	properties := BlobProperties{}
+	if props.ContentLength != nil {
+		properties.Size = *props.ContentLength
+	}
+	if props.ETag != nil {
+		properties.ETag = string(*props.ETag)
+	}
+	if props.LastModified != nil {
+		properties.LastModified = *props.LastModified
+	}
+	if props.ContentType != nil {
+		properties.ContentType = *props.ContentType
+	}
+
+	return &azureAudioBlob{
+		ctx:        ctx,
+		db:         db,
+		filename:   filename,
+		properties: properties,
+	}, nil
+}
+
+type azureAudioBlob struct {
+	ctx        context.Context
+	db         *AzureBlobDatabase
+	filename   string
+	properties BlobProperties
+	offset     int64
+	body       io.ReadCloser
+}
+
+func (b *azureAudioBlob) Properties() BlobProperties {
+	return b.properties
+}
+
+func (b *azureAudioBlob) Read(p []byte) (int, error) {
+	if b.offset >= b.properties.Size {
+		return 0, io.EOF
+	}
+
+	if b.body == nil {
+		options := &azblob.DownloadStreamOptions{
+			Range: blob.HTTPRange{Offset: b.offset},
+		}
+		// Fail rather than mix bytes from two versions if the clip is replaced mid read
+		if b.properties.ETag != "" {
+			etag := azcore.ETag(b.properties.ETag)
+			options.AccessConditions = &blob.AccessConditions{
+				ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfMatch: &etag},
+			}
+		}
+
+		start := time.Now()
+		resp, err := b.db.serviceClient.DownloadStream(b.ctx, b.db.containerName, b.filename, options)
+		metrics.ObserveStorage("download", start, err)
+		if err != nil {
+			return 0, fmt.Errorf("failed to download audio: %w", err)
+		}
+		b.body = resp.Body
+	}
+
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	return n, err
+}
+
+func (b *azureAudioBlob) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = b.offset + offset
+	case io.SeekEnd:
+		next = b.properties.Size + offset
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+	if next < 0 {
+		return 0, fmt.Errorf("negative position: %d", next)
+	}
+
+	if next != b.offset && b.body != nil {
+		b.body.Close()
+		b.body = nil
+	}
+	b.offset = next
+	return next, nil
+}
+
+func (b *azureAudioBlob) Close() error {
+	if b.body == nil {
+		return nil
+	}
+	err := b.body.Close()
+	b.body = nil
+	return err
+}
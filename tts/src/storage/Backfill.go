@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"tts/src/logging"
+)
+
+// BackfillReport counts the clips a backfill went through
+type BackfillReport struct {
+	Listed   int
+	Recorded int
+	Skipped  int
+	Failed   int
+}
+
+// Backfill records every clip under prefix in db that the manifest does not
+// know yet, such as clips stored before the manifest was enabled or written
+// by tts-migrate and tts-batch -to. Metadata comes from each clip's sidecar,
+// or only the blob's modification time when it has none.
+func (m *Manifest) Backfill(ctx context.Context, db BlobDatabase, prefix string, dryRun bool) (BackfillReport, error) {
+	var report BackfillReport
+
+	known, err := m.clipPaths(ctx)
+	if err != nil {
+		return report, err
+	}
+	items, err := db.ListBlobs(ctx, prefix)
+	if err != nil {
+		return report, err
+	}
+
+	for _, item := range items {
+		if _, _, _, ok := ParseClipPath(item.Name); !ok {
+			continue
+		}
+		report.Listed++
+		if known[item.Name] {
+			report.Skipped++
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		metadata := ClipMetadata{CreatedAt: item.LastModified}
+		data, err := db.ReadBlob(ctx, metadataPath(item.Name))
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(data, &metadata); err != nil {
+				logging.FromContext(ctx).Warn("failed to decode clip metadata", "clip", item.Name, "error", err)
+				report.Failed++
+				continue
+			}
+		case !errors.Is(err, ErrNotFound):
+			return report, fmt.Errorf("failed to read metadata of %s: %w", item.Name, err)
+		}
+
+		if !dryRun {
+			if err := m.SaveClip(ctx, item.Name, db.URL(item.Name), metadata); err != nil {
+				return report, err
+			}
+		}
+		report.Recorded++
+	}
+	return report, nil
+}
+
+// clipPaths returns the path of every recorded clip
+func (m *Manifest) clipPaths(ctx context.Context) (map[string]bool, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT path FROM tts_clips")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+	defer rows.Close()
+
+	paths := make(map[string]bool)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to list clips: %w", err)
+		}
+		paths[path] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+	return paths, nil
+}
@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
+	"strings"
 	"time"
+	"tts/src/metrics"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
 )
 
 const (
@@ -21,11 +23,40 @@ const (
 
 type BlobDatabase interface {
 	InsertTTSAudio(filename string, data []byte) (string, error)
+	OpenTTSAudio(ctx context.Context, filename string) (AudioBlob, error)
+	DeleteTTSAudio(ctx context.Context, filename string) error
+	// WriteBlob, ReadBlob and ListBlobs work on any object in the container, such
+	// as clip metadata and archived versions
+	WriteBlob(ctx context.Context, name string, data []byte, contentType string) error
+	// WriteBlobFrom stores everything read from r without holding it in memory
+	WriteBlobFrom(ctx context.Context, name string, r io.Reader, contentType string) error
+	ReadBlob(ctx context.Context, name string) ([]byte, error)
+	ListBlobs(ctx context.Context, prefix string) ([]BlobItem, error)
+	// CopyBlob copies src to dst within the store without downloading it,
+	// returning ErrNotFound if src does not exist
+	CopyBlob(ctx context.Context, src, dst string) error
+	// URL is where a blob is reached, as InsertTTSAudio returns it
+	URL(name string) string
+	Ping(ctx context.Context) error
+}
+
+// URLSigner is implemented by stores that can hand clients a time-limited URL
+// to read a blob directly
+type URLSigner interface {
+	SignedURL(ctx context.Context, name string, expires time.Time) (string, error)
+}
+
+// BlobItem is one entry of a blob listing
+type BlobItem struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
 }
 
 type AzureBlobDatabase struct {
 	serviceClient *azblob.Client
 	serviceURL    string
+	publicURL     string
 	containerName string
 }
 
@@ -34,6 +65,9 @@ type AzureBlobOptions struct {
 	AccountKey    string
 	BlobURL       string
 	ContainerName string
+	// PublicBlobURL replaces BlobURL in signed URLs, for when clients reach the
+	// storage account at another address than the service does
+	PublicBlobURL string
 }
 
 // NewAzureBlobDatabase creates a new Azure Blob Database client using azblob SDK
@@ -68,6 +102,7 @@ func NewAzureBlobDatabase(options AzureBlobOptions) (*AzureBlobDatabase, error)
 	db := &AzureBlobDatabase{
 		serviceClient: serviceClient,
 		serviceURL:    options.BlobURL,
+		publicURL:     strings.TrimSuffix(options.PublicBlobURL, "/"),
 		containerName: options.ContainerName,
 	}
 
@@ -85,74 +120,202 @@ func NewAzureBlobDatabase(options AzureBlobOptions) (*AzureBlobDatabase, error)
 	return db, nil
 }
 
-func ConnectToAzurite() (*AzureBlobDatabase, error) {
-	host := "localhost"
-	// For Docker environment, get the host from environment variable
-	// or use Docker service name if running in Docker Compose
-	if os.Getenv("DOCKER_ENV") == "true" {
-		host = "mingxue_azurite"
+func (db *AzureBlobDatabase) InsertTTSAudio(filename string, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.WriteBlob(ctx, filename, data, "audio/wav"); err != nil {
+		return "", fmt.Errorf("failed to upload audio: %w", err)
 	}
 
-	options := AzureBlobOptions{
-		BlobURL:       fmt.Sprintf("http://%s:10000/%s", host, DefaultAzuriteAccountName),
-		ContainerName: "tts-audio",
+	return db.URL(filename), nil
+}
+
+// URL is the address of a blob under the stored service URL
+func (db *AzureBlobDatabase) URL(name string) string {
+	return fmt.Sprintf("%s/%s/%s", db.serviceURL, db.containerName, name)
+}
+
+// sasClockSkew backdates the start of a SAS so clients whose clocks run
+// slightly behind the storage account can use it at once
+const sasClockSkew = 5 * time.Minute
+
+// SignedURL returns a read-only SAS URL for a blob that stops working at expires
+func (db *AzureBlobDatabase) SignedURL(ctx context.Context, name string, expires time.Time) (string, error) {
+	start := time.Now().Add(-sasClockSkew)
+	url, err := db.serviceClient.ServiceClient().NewContainerClient(db.containerName).NewBlobClient(name).
+		GetSASURL(sas.BlobPermissions{Read: true}, expires, &blob.GetSASURLOptions{StartTime: &start})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign url for %s: %w", name, err)
+	}
+	if db.publicURL != "" {
+		url = db.publicURL + strings.TrimPrefix(url, strings.TrimSuffix(db.serviceURL, "/"))
 	}
-	return NewAzureBlobDatabase(options)
+	return url, nil
 }
 
-func (db *AzureBlobDatabase) InsertTTSAudio(filename string, data []byte) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// DeleteTTSAudio removes a stored clip, returning ErrNotFound if there is none
+func (db *AzureBlobDatabase) DeleteTTSAudio(ctx context.Context, filename string) error {
+	start := time.Now()
+	_, err := db.serviceClient.DeleteBlob(ctx, db.containerName, filename, nil)
+	metrics.ObserveStorage("delete", start, err)
+	if err != nil {
+		if IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, filename)
+		}
+		return fmt.Errorf("failed to delete audio: %w", err)
+	}
+	return nil
+}
 
+// WriteBlob uploads data under name, replacing any existing blob
+func (db *AzureBlobDatabase) WriteBlob(ctx context.Context, name string, data []byte, contentType string) error {
+	start := time.Now()
 	_, err := db.serviceClient.UploadBuffer(
 		ctx,
 		db.containerName,
-		filename,
+		name,
 		data,
 		&azblob.UploadBufferOptions{
 			HTTPHeaders: &blob.HTTPHeaders{
-				BlobContentType: ptrTo("audio/wav"),
+				BlobContentType: ptrTo(contentType),
 			},
 		},
 	)
+	metrics.ObserveStorage("upload", start, err)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload audio: %w", err)
+		return fmt.Errorf("failed to upload %s: %w", name, err)
 	}
-
-	// Generate the public URL using stored service URL
-	return fmt.Sprintf("%s/%s/%s",
-		db.serviceURL,
-		db.containerName,
-		filename), nil
+	return nil
 }
 
-// Add this method to your AzureBlobDatabase struct
-func (db *AzureBlobDatabase) GetTTSAudio(id string, sentence bool) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+func (db *AzureBlobDatabase) WriteBlobFrom(ctx context.Context, name string, r io.Reader, contentType string) error {
+	start := time.Now()
+	_, err := db.serviceClient.UploadStream(ctx, db.containerName, name, r, &azblob.UploadStreamOptions{
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: ptrTo(contentType),
+		},
+	})
+	metrics.ObserveStorage("upload", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
 
-	// Construct blob path: "tts/{id}.wav"
-	path := ""
-	if sentence {
-		path = "tts/sentence/"
-	} else {
-		path = "tts/word/"
+// ReadBlob downloads a whole blob, returning ErrNotFound if there is none
+func (db *AzureBlobDatabase) ReadBlob(ctx context.Context, name string) ([]byte, error) {
+	start := time.Now()
+	resp, err := db.serviceClient.DownloadStream(ctx, db.containerName, name, nil)
+	metrics.ObserveStorage("download", start, err)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, fmt.Errorf("failed to download %s: %w", name, err)
 	}
-	blobName := fmt.Sprintf("%s%s.wav", path, id)
+	defer resp.Body.Close()
 
-	// Download the blob
-	resp, err := db.serviceClient.DownloadStream(ctx, db.containerName, blobName, nil)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download audio: %w", err)
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
 	}
+	return data, nil
+}
+
+// ListBlobs returns every blob whose name starts with prefix, in name order
+func (db *AzureBlobDatabase) ListBlobs(ctx context.Context, prefix string) ([]BlobItem, error) {
+	start := time.Now()
+	pager := db.serviceClient.NewListBlobsFlatPager(db.containerName, &azblob.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+
+	var items []BlobItem
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			metrics.ObserveStorage("list", start, err)
+			return nil, fmt.Errorf("failed to list blobs under %s: %w", prefix, err)
+		}
+		for _, b := range page.Segment.BlobItems {
+			if b.Name == nil {
+				continue
+			}
+			item := BlobItem{Name: *b.Name}
+			if b.Properties != nil {
+				if b.Properties.ContentLength != nil {
+					item.Size = *b.Properties.ContentLength
+				}
+				if b.Properties.LastModified != nil {
+					item.LastModified = *b.Properties.LastModified
+				}
+			}
+			items = append(items, item)
+		}
+	}
+	metrics.ObserveStorage("list", start, nil)
+	return items, nil
+}
 
-	// Read the response body
-	downloadedData, err := io.ReadAll(resp.Body)
+// copyPollInterval is how often a copy the account did not finish at once is checked
+const copyPollInterval = 200 * time.Millisecond
+
+// CopyBlob has the storage account copy src to dst. Copies within an account
+// usually complete before the request returns; others are polled until done.
+func (db *AzureBlobDatabase) CopyBlob(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	container := db.serviceClient.ServiceClient().NewContainerClient(db.containerName)
+	target := container.NewBlobClient(dst)
+	resp, err := target.StartCopyFromURL(ctx, container.NewBlobClient(src).URL(), nil)
+	if err == nil && resp.CopyStatus != nil && *resp.CopyStatus == blob.CopyStatusTypePending {
+		err = db.waitForCopy(ctx, target)
+	}
+	metrics.ObserveStorage("copy", start, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		if IsNotFound(err) {
+			return fmt.Errorf("%w: %s", ErrNotFound, src)
+		}
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
 	}
+	return nil
+}
 
-	return downloadedData, nil
+func (db *AzureBlobDatabase) waitForCopy(ctx context.Context, target *blob.Client) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(copyPollInterval):
+		}
+		props, err := target.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if props.CopyStatus == nil {
+			return nil
+		}
+		switch *props.CopyStatus {
+		case blob.CopyStatusTypeSuccess:
+			return nil
+		case blob.CopyStatusTypePending:
+			continue
+		default:
+			description := ""
+			if props.CopyStatusDescription != nil {
+				description = *props.CopyStatusDescription
+			}
+			return fmt.Errorf("copy %s: %s", *props.CopyStatus, description)
+		}
+	}
+}
+
+// Ping checks the container is reachable
+func (db *AzureBlobDatabase) Ping(ctx context.Context) error {
+	_, err := db.serviceClient.ServiceClient().NewContainerClient(db.containerName).GetProperties(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reach container %s: %w", db.containerName, err)
+	}
+	return nil
 }
 
 func isContainerExistsError(err error) bool {
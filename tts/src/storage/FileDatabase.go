@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"tts/src/metrics"
+)
+
+// tempPrefix marks files being written, which listings skip
+const tempPrefix = ".tmp-"
+
+// FileBlobDatabase keeps blobs as files under a root directory, for local
+// development and hosts that serve the directory themselves
+type FileBlobDatabase struct {
+	root    string
+	baseURL string
+}
+
+type FileBlobOptions struct {
+	Root string
+	// BaseURL is prefixed to blob names to build the URLs handed to clients
+	BaseURL string
+}
+
+func NewFileBlobDatabase(options FileBlobOptions) (*FileBlobDatabase, error) {
+	if options.Root == "" {
+		return nil, errors.New("filesystem storage needs a root directory")
+	}
+	if err := os.MkdirAll(options.Root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage root: %w", err)
+	}
+	return &FileBlobDatabase{root: options.Root, baseURL: strings.TrimSuffix(options.BaseURL, "/")}, nil
+}
+
+func (db *FileBlobDatabase) InsertTTSAudio(filename string, data []byte) (string, error) {
+	if err := db.WriteBlob(context.Background(), filename, data, "audio/wav"); err != nil {
+		return "", fmt.Errorf("failed to upload audio: %w", err)
+	}
+	return db.URL(filename), nil
+}
+
+// URL is where the directory is served, followed by the blob name
+func (db *FileBlobDatabase) URL(name string) string {
+	return db.baseURL + "/" + name
+}
+
+func (db *FileBlobDatabase) OpenTTSAudio(ctx context.Context, filename string) (AudioBlob, error) {
+	start := time.Now()
+	file, err := os.Open(db.path(filename))
+	metrics.ObserveStorage("stat", start, err)
+	if err != nil {
+		return nil, db.wrap(err, filename)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read audio properties: %w", err)
+	}
+
+	return &fileAudioBlob{File: file, properties: BlobProperties{
+		Size:         info.Size(),
+		ETag:         fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		LastModified: info.ModTime(),
+		ContentType:  ContentType(filename),
+	}}, nil
+}
+
+type fileAudioBlob struct {
+	*os.File
+	properties BlobProperties
+}
+
+func (b *fileAudioBlob) Properties() BlobProperties {
+	return b.properties
+}
+
+func (db *FileBlobDatabase) DeleteTTSAudio(ctx context.Context, filename string) error {
+	start := time.Now()
+	err := os.Remove(db.path(filename))
+	metrics.ObserveStorage("delete", start, err)
+	if err != nil {
+		return db.wrap(err, filename)
+	}
+	return nil
+}
+
+// WriteBlob writes to a temporary file and renames it, so readers never see a
+// partial blob. The content type is implied by the extension.
+func (db *FileBlobDatabase) WriteBlob(ctx context.Context, name string, data []byte, contentType string) error {
+	start := time.Now()
+	err := db.write(name, bytes.NewReader(data))
+	metrics.ObserveStorage("upload", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+func (db *FileBlobDatabase) WriteBlobFrom(ctx context.Context, name string, r io.Reader, contentType string) error {
+	start := time.Now()
+	err := db.write(name, r)
+	metrics.ObserveStorage("upload", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+func (db *FileBlobDatabase) write(name string, r io.Reader) error {
+	target := db.path(name)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(target), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, r); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), target)
+}
+
+// CopyBlob copies the file of src to dst, going through a temporary file like WriteBlob
+func (db *FileBlobDatabase) CopyBlob(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	file, err := os.Open(db.path(src))
+	if err != nil {
+		metrics.ObserveStorage("copy", start, err)
+		return db.wrap(err, src)
+	}
+	defer file.Close()
+	err = db.write(dst, file)
+	metrics.ObserveStorage("copy", start, err)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", src, dst, err)
+	}
+	return nil
+}
+
+func (db *FileBlobDatabase) ReadBlob(ctx context.Context, name string) ([]byte, error) {
+	start := time.Now()
+	data, err := os.ReadFile(db.path(name))
+	metrics.ObserveStorage("download", start, err)
+	if err != nil {
+		return nil, db.wrap(err, name)
+	}
+	return data, nil
+}
+
+// ListBlobs returns every blob whose name starts with prefix, in name order
+func (db *FileBlobDatabase) ListBlobs(ctx context.Context, prefix string) ([]BlobItem, error) {
+	start := time.Now()
+
+	// Walk only the deepest directory the prefix names
+	dir := db.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = db.path(prefix[:i])
+	}
+
+	var items []BlobItem
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(db.root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if !strings.HasPrefix(name, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, BlobItem{Name: name, Size: info.Size(), LastModified: info.ModTime()})
+		return ctx.Err()
+	})
+	metrics.ObserveStorage("list", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs under %s: %w", prefix, err)
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	return items, nil
+}
+
+// Ping checks the root directory is still there
+func (db *FileBlobDatabase) Ping(ctx context.Context) error {
+	if _, err := os.Stat(db.root); err != nil {
+		return fmt.Errorf("failed to reach storage root: %w", err)
+	}
+	return nil
+}
+
+// path maps a blob name to its file, refusing to leave the root
+func (db *FileBlobDatabase) path(name string) string {
+	return filepath.Join(db.root, filepath.FromSlash(filepath.Clean("/"+name)))
+}
+
+func (db *FileBlobDatabase) wrap(err error, name string) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	return fmt.Errorf("failed to access %s: %w", name, err)
+}
+
+// contentTypes covers the blobs this service stores, which the mime package
+// only knows of when the host has a mime.types file
+var contentTypes = map[string]string{
+	".wav":  "audio/wav",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".flac": "audio/flac",
+	".json": "application/json",
+}
+
+// ContentType guesses the content type of a blob from its extension
+func ContentType(name string) string {
+	if contentType, ok := contentTypes[strings.ToLower(path.Ext(name))]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"tts/src/namespace"
+)
+
+// Clips of the default namespace live under tts/, those of any other
+// namespace under tenants/<namespace>/tts/, so namespaces never share a key:
+//
+//	tts/word/42.wav
+//	tenants/acme/tts/sentence/7.wav
+const (
+	clipRoot   = "tts/"
+	tenantRoot = "tenants/"
+)
+
+// clipPathPattern matches the current clip of a word or sentence
+var clipPathPattern = regexp.MustCompile(`^(?:tenants/([a-z0-9][a-z0-9_-]*)/)?tts/(word|sentence)/(\d+)\.wav$`)
+
+// clipBlobPattern matches a clip, its metadata sidecar and its archived versions
+var clipBlobPattern = regexp.MustCompile(`^((?:tenants/[a-z0-9][a-z0-9_-]*/)?tts/(?:word|sentence)/\d+)(\.wav|\.json|/versions/[^/]+)$`)
+
+// Prefix is the key prefix of every clip of namespace ns
+func Prefix(ns string) string {
+	if ns == namespace.Default {
+		return clipRoot
+	}
+	return tenantRoot + ns + "/" + clipRoot
+}
+
+// ClipPath is the key a word or sentence clip of namespace ns is stored under
+func ClipPath(ns, kind, id string) string {
+	return Prefix(ns) + kind + "/" + id + ".wav"
+}
+
+// ParseClipPath returns the namespace, kind and context id of a clip path
+func ParseClipPath(path string) (string, string, int, bool) {
+	match := clipPathPattern.FindStringSubmatch(path)
+	if match == nil {
+		return "", "", 0, false
+	}
+	id, err := strconv.Atoi(match[3])
+	if err != nil {
+		return "", "", 0, false
+	}
+	return match[1], match[2], id, true
+}
+
+// ClipOfBlob returns the path of the clip a blob belongs to, and whether the
+// blob is one of its archived versions rather than the current clip or sidecar
+func ClipOfBlob(name string) (string, bool, bool) {
+	match := clipBlobPattern.FindStringSubmatch(name)
+	if match == nil {
+		return "", false, false
+	}
+	return match[1] + ".wav", strings.HasPrefix(match[2], "/versions/"), true
+}
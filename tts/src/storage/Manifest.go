@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+// Manifest drivers
+const (
+	DriverSQLite = "sqlite"
+	DriverMySQL  = "mysql"
+)
+
+// missingBatch bounds the number of ids in one IN (...) lookup
+const missingBatch = 500
+
+// JobRecord is a synthesis job as kept in the manifest
+type JobRecord struct {
+	ID         string
+	Namespace  string
+	Kind       string
+	Engine     string
+	Caller     string
+	Status     string
+	Words      int
+	Error      string
+	CreatedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// WordRecord is the outcome of the word at Index of a job
+type WordRecord struct {
+	Index         int
+	ContextID     int
+	Text          string
+	Pronunciation string
+	Status        string
+	URL           string
+	Error         string
+}
+
+// ExpectedClip is a word or sentence the manifest knows should have audio,
+// with the text it was last synthesized from
+type ExpectedClip struct {
+	ContextID     int
+	Text          string
+	Pronunciation string
+}
+
+// Manifest records jobs, their per-word results and the metadata of every
+// stored clip in SQLite or MariaDB, so questions such as which words have no
+// audio can be answered without listing blobs. Tables are prefixed with tts_
+// as the MariaDB database is shared with the backend.
+type Manifest struct {
+	db     *sql.DB
+	driver string
+}
+
+// OpenManifest connects to the database and applies any pending migrations
+func OpenManifest(ctx context.Context, driver, dsn string) (*Manifest, error) {
+	if driver != DriverSQLite && driver != DriverMySQL {
+		return nil, fmt.Errorf("unsupported manifest driver %q", driver)
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite allows a single writer, so share one connection rather than fail with SQLITE_BUSY
+		db.SetMaxOpenConns(1)
+		if _, err := db.ExecContext(ctx, "PRAGMA journal_mode = WAL"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure manifest: %w", err)
+		}
+	}
+
+	m := &Manifest{db: db, driver: driver}
+	if err := m.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Manifest) Close() error {
+	return m.db.Close()
+}
+
+func (m *Manifest) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+// Driver is the database the manifest is kept in
+func (m *Manifest) Driver() string {
+	return m.driver
+}
+
+// SaveJob inserts or updates a job
+func (m *Manifest) SaveJob(ctx context.Context, job JobRecord) error {
+	var finishedAt sql.NullInt64
+	if job.FinishedAt != nil {
+		finishedAt = sql.NullInt64{Int64: job.FinishedAt.UnixMilli(), Valid: true}
+	}
+
+	query := m.upsert("tts_jobs", []string{"id"},
+		[]string{"namespace", "kind", "engine", "caller", "status", "words", "error_message", "created_at", "finished_at"})
+	_, err := m.db.ExecContext(ctx, query, job.ID, job.Namespace, job.Kind, job.Engine, job.Caller, job.Status, job.Words,
+		job.Error, job.CreatedAt.UnixMilli(), finishedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// SaveJobWords replaces the per-word results of a job
+func (m *Manifest) SaveJobWords(ctx context.Context, jobID string, words []WordRecord) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to record words of job %s: %w", jobID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tts_job_words WHERE job_id = ?", jobID); err != nil {
+		return fmt.Errorf("failed to record words of job %s: %w", jobID, err)
+	}
+	insert, err := tx.PrepareContext(ctx, "INSERT INTO tts_job_words "+
+		"(job_id, word_index, context_id, word_text, pronunciation, status, url, error_message) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to record words of job %s: %w", jobID, err)
+	}
+	defer insert.Close()
+
+	for _, word := range words {
+		if _, err := insert.ExecContext(ctx, jobID, word.Index, word.ContextID, word.Text, word.Pronunciation,
+			word.Status, word.URL, word.Error); err != nil {
+			return fmt.Errorf("failed to record words of job %s: %w", jobID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record words of job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// SaveClip records the clip stored at path. Paths that are not word or
+// sentence clips are ignored.
+func (m *Manifest) SaveClip(ctx context.Context, path, url string, metadata ClipMetadata) error {
+	ns, kind, contextID, ok := ParseClipPath(path)
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode clip metadata: %w", err)
+	}
+
+	query := m.upsert("tts_clips", []string{"path"},
+		[]string{"namespace", "kind", "context_id", "url", "provider", "voice", "ssml_sha256", "duration_seconds", "metadata", "created_at"})
+	_, err = m.db.ExecContext(ctx, query, path, ns, kind, contextID, url, metadata.Provider, metadata.Voice, metadata.SSMLHash,
+		metadata.DurationSeconds, string(data), metadata.CreatedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to record clip %s: %w", path, err)
+	}
+	return nil
+}
+
+// DeleteClip forgets the clip stored at path, along with the job words of its
+// context id, so ExpectedClips no longer lists a clip that was deleted on purpose
+func (m *Manifest) DeleteClip(ctx context.Context, path string) error {
+	ns, kind, contextID, ok := ParseClipPath(path)
+	if !ok {
+		if _, err := m.db.ExecContext(ctx, "DELETE FROM tts_clips WHERE path = ?", path); err != nil {
+			return fmt.Errorf("failed to forget clip %s: %w", path, err)
+		}
+		return nil
+	}
+	if err := m.Forget(ctx, ns, kind, []int{contextID}); err != nil {
+		return fmt.Errorf("failed to forget clip %s: %w", path, err)
+	}
+	return nil
+}
+
+// Forget removes every record of the given context ids of kind in namespace
+// ns, for words and sentences that no longer exist
+func (m *Manifest) Forget(ctx context.Context, ns, kind string, ids []int) error {
+	for start := 0; start < len(ids); start += missingBatch {
+		batch := ids[start:min(start+missingBatch, len(ids))]
+
+		args := []any{ns, kind}
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		in := "(?" + strings.Repeat(", ?", len(batch)-1) + ")"
+
+		if _, err := m.db.ExecContext(ctx, "DELETE FROM tts_clips WHERE namespace = ? AND kind = ? AND context_id IN "+in, args...); err != nil {
+			return fmt.Errorf("failed to forget clips: %w", err)
+		}
+		_, err := m.db.ExecContext(ctx, "DELETE FROM tts_job_words WHERE job_id IN "+
+			"(SELECT id FROM tts_jobs WHERE namespace = ? AND kind = ?) AND context_id IN "+in, args...)
+		if err != nil {
+			return fmt.Errorf("failed to forget job words: %w", err)
+		}
+	}
+	return nil
+}
+
+// MissingAudio returns the context ids of kind ("word" or "sentence") in
+// namespace ns that have no clip recorded, in the order given and without duplicates
+func (m *Manifest) MissingAudio(ctx context.Context, ns, kind string, ids []int) ([]int, error) {
+	stored := make(map[int]bool, len(ids))
+	for start := 0; start < len(ids); start += missingBatch {
+		batch := ids[start:min(start+missingBatch, len(ids))]
+
+		args := []any{ns, kind}
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		query := "SELECT context_id FROM tts_clips WHERE namespace = ? AND kind = ? AND context_id IN (?" +
+			strings.Repeat(", ?", len(batch)-1) + ")"
+
+		rows, err := m.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up clips: %w", err)
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to look up clips: %w", err)
+			}
+			stored[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up clips: %w", err)
+		}
+	}
+
+	missing := []int{}
+	for _, id := range ids {
+		if !stored[id] {
+			missing = append(missing, id)
+			stored[id] = true
+		}
+	}
+	return missing, nil
+}
+
+// ExpectedClips lists every context id of kind in namespace ns that has a
+// recorded clip or was part of a job, ordered by id. The text comes from the
+// clip's metadata, or for words that never got a clip from the newest job they were in.
+func (m *Manifest) ExpectedClips(ctx context.Context, ns, kind string) ([]ExpectedClip, error) {
+	expected := make(map[int]ExpectedClip)
+
+	rows, err := m.db.QueryContext(ctx, "SELECT context_id, metadata FROM tts_clips WHERE namespace = ? AND kind = ?", ns, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+	for rows.Next() {
+		var id int
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list clips: %w", err)
+		}
+		var metadata ClipMetadata
+		if err := json.Unmarshal([]byte(data), &metadata); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to decode metadata of clip %d: %w", id, err)
+		}
+		expected[id] = ExpectedClip{ContextID: id, Text: metadata.Text, Pronunciation: metadata.Pronunciation}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clips: %w", err)
+	}
+
+	rows, err = m.db.QueryContext(ctx, `SELECT w.context_id, w.word_text, w.pronunciation
+		FROM tts_job_words w JOIN tts_jobs j ON j.id = w.job_id
+		WHERE j.namespace = ? AND j.kind = ? ORDER BY j.created_at DESC`, ns, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job words: %w", err)
+	}
+	for rows.Next() {
+		var clip ExpectedClip
+		if err := rows.Scan(&clip.ContextID, &clip.Text, &clip.Pronunciation); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list job words: %w", err)
+		}
+		if _, ok := expected[clip.ContextID]; !ok {
+			expected[clip.ContextID] = clip
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job words: %w", err)
+	}
+
+	clips := make([]ExpectedClip, 0, len(expected))
+	for _, clip := range expected {
+		clips = append(clips, clip)
+	}
+	sort.Slice(clips, func(i, j int) bool { return clips[i].ContextID < clips[j].ContextID })
+	return clips, nil
+}
+
+// upsert builds an INSERT of keys and columns that updates columns when a row
+// with the same keys exists
+func (m *Manifest) upsert(table string, keys, columns []string) string {
+	all := append(append([]string{}, keys...), columns...)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)", table, strings.Join(all, ", "), strings.Repeat(", ?", len(all)-1))
+
+	updates := make([]string, len(columns))
+	for i, column := range columns {
+		if m.driver == DriverMySQL {
+			updates[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+		} else {
+			updates[i] = fmt.Sprintf("%s = excluded.%s", column, column)
+		}
+	}
+	if m.driver == DriverMySQL {
+		return query + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+	return query + fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(updates, ", "))
+}
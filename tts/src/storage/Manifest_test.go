@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testDrivers returns the manifest databases to test against: a SQLite file,
+// and MariaDB when TTS_TEST_MYSQL_DSN names a database the test may wipe
+func testDrivers(t *testing.T) map[string]string {
+	drivers := map[string]string{DriverSQLite: filepath.Join(t.TempDir(), "manifest.db")}
+	if dsn := os.Getenv("TTS_TEST_MYSQL_DSN"); dsn != "" {
+		drivers[DriverMySQL] = dsn
+	}
+	return drivers
+}
+
+// dropTables removes every tts_ table, so MariaDB tests start from an empty schema
+func dropTables(t *testing.T, driver, dsn string) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, table := range []string{"tts_job_words", "tts_jobs", "tts_clips", "tts_schema_migrations"} {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func openTestManifest(t *testing.T, driver, dsn string) *Manifest {
+	dropTables(t, driver, dsn)
+	m, err := OpenManifest(context.Background(), driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+func TestUpsert(t *testing.T) {
+	keys, columns := []string{"path"}, []string{"url", "voice"}
+
+	got := (&Manifest{driver: DriverSQLite}).upsert("tts_clips", keys, columns)
+	want := "INSERT INTO tts_clips (path, url, voice) VALUES (?, ?, ?) " +
+		"ON CONFLICT (path) DO UPDATE SET url = excluded.url, voice = excluded.voice"
+	if got != want {
+		t.Errorf("sqlite upsert\n got %s\nwant %s", got, want)
+	}
+
+	got = (&Manifest{driver: DriverMySQL}).upsert("tts_clips", keys, columns)
+	want = "INSERT INTO tts_clips (path, url, voice) VALUES (?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE url = VALUES(url), voice = VALUES(voice)"
+	if got != want {
+		t.Errorf("mysql upsert\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestMigrationsUpgradeExistingData(t *testing.T) {
+	for driver, dsn := range testDrivers(t) {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			dropTables(t, driver, dsn)
+
+			// A manifest created by the first release, holding a job and a clip
+			db, err := sql.Open(driver, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			if driver == DriverSQLite {
+				db.SetMaxOpenConns(1)
+			}
+			old := &Manifest{db: db, driver: driver}
+			if _, err := db.Exec("CREATE TABLE tts_schema_migrations (version INT NOT NULL PRIMARY KEY, applied_at BIGINT NOT NULL)" +
+				old.tableOptions()); err != nil {
+				t.Fatal(err)
+			}
+			if err := old.apply(ctx, migrations[0].version, migrations[0].statements); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO tts_jobs (id, kind, engine, caller, status, words, error_message, created_at) " +
+				"VALUES ('j1', 'word', 'azure', 'backend', 'succeeded', 1, '', 1)"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec("INSERT INTO tts_job_words (job_id, word_index, context_id, status, url, error_message) " +
+				"VALUES ('j1', 0, 7, 'uploaded', '', '')"); err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := OpenManifest(ctx, driver, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer m.Close()
+
+			var version int
+			if err := m.db.QueryRow("SELECT MAX(version) FROM tts_schema_migrations").Scan(&version); err != nil {
+				t.Fatal(err)
+			}
+			if want := migrations[len(migrations)-1].version; version != want {
+				t.Fatalf("schema version %d, want %d", version, want)
+			}
+
+			// The old job moved to the default namespace, its word to empty text
+			expected, err := m.ExpectedClips(ctx, "", "word")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(expected) != 1 || expected[0] != (ExpectedClip{ContextID: 7}) {
+				t.Fatalf("expected clips %+v", expected)
+			}
+
+			// Opening again applies nothing
+			again, err := OpenManifest(ctx, driver, dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again.Close()
+		})
+	}
+}
+
+func TestSaveClipUpdatesExistingRow(t *testing.T) {
+	for driver, dsn := range testDrivers(t) {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			m := openTestManifest(t, driver, dsn)
+
+			created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			for _, text := range []string{"你好", "您好"} {
+				err := m.SaveClip(ctx, "tenants/acme/tts/word/42.wav", "http://blobs/42.wav",
+					ClipMetadata{Provider: "azure", Voice: "v", Text: text, Pronunciation: "nin2 hao3", CreatedAt: created})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.SaveClip(ctx, "tts/sentence/1.wav", "u", ClipMetadata{Text: "其他"}); err != nil {
+				t.Fatal(err)
+			}
+			// Paths that are not clips are ignored
+			if err := m.SaveClip(ctx, "tts/word/42/versions/x.wav", "u", ClipMetadata{}); err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := m.ExpectedClips(ctx, "acme", "word")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []ExpectedClip{{ContextID: 42, Text: "您好", Pronunciation: "nin2 hao3"}}
+			if !slices.Equal(expected, want) {
+				t.Fatalf("expected clips %+v, want %+v", expected, want)
+			}
+
+			missing, err := m.MissingAudio(ctx, "acme", "word", []int{41, 42, 41, 43})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(missing, []int{41, 43}) {
+				t.Fatalf("missing %v", missing)
+			}
+
+			job := JobRecord{ID: "j1", Namespace: "acme", Kind: "word", Status: "succeeded", Words: 1, CreatedAt: created}
+			if err := m.SaveJob(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.SaveJobWords(ctx, job.ID, []WordRecord{{ContextID: 42, Text: "您好", Status: "uploaded"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.DeleteClip(ctx, "tenants/acme/tts/word/42.wav"); err != nil {
+				t.Fatal(err)
+			}
+			if missing, _ := m.MissingAudio(ctx, "acme", "word", []int{42}); !slices.Equal(missing, []int{42}) {
+				t.Fatalf("deleted clip still recorded: missing %v", missing)
+			}
+			// Nor is it expected from the job that made it
+			if expected, _ := m.ExpectedClips(ctx, "acme", "word"); len(expected) != 0 {
+				t.Fatalf("deleted clip still expected: %+v", expected)
+			}
+		})
+	}
+}
+
+func TestSaveJobWordsReplacesWords(t *testing.T) {
+	for driver, dsn := range testDrivers(t) {
+		t.Run(driver, func(t *testing.T) {
+			ctx := context.Background()
+			m := openTestManifest(t, driver, dsn)
+
+			job := JobRecord{ID: "j1", Kind: "word", Engine: "azure", Caller: "backend", Status: "running", Words: 2, CreatedAt: time.Now()}
+			if err := m.SaveJob(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+			finished := time.Now()
+			job.Status, job.FinishedAt = "succeeded", &finished
+			if err := m.SaveJob(ctx, job); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, text := range []string{"旧", "新"} {
+				words := []WordRecord{{Index: 0, ContextID: 5, Text: text, Status: "uploaded"}, {Index: 1, ContextID: 6, Text: "六", Status: "failed"}}
+				if err := m.SaveJobWords(ctx, job.ID, words); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var jobs, words int
+			if err := m.db.QueryRow("SELECT COUNT(*) FROM tts_jobs").Scan(&jobs); err != nil {
+				t.Fatal(err)
+			}
+			if err := m.db.QueryRow("SELECT COUNT(*) FROM tts_job_words").Scan(&words); err != nil {
+				t.Fatal(err)
+			}
+			if jobs != 1 || words != 2 {
+				t.Fatalf("%d jobs and %d words recorded, want 1 and 2", jobs, words)
+			}
+
+			expected, err := m.ExpectedClips(ctx, "", "word")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []ExpectedClip{{ContextID: 5, Text: "新"}, {ContextID: 6, Text: "六"}}
+			if !slices.Equal(expected, want) {
+				t.Fatalf("expected clips %+v, want %+v", expected, want)
+			}
+		})
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	m := openTestManifest(t, DriverSQLite, filepath.Join(t.TempDir(), "manifest.db"))
+	db, err := NewFileBlobDatabase(FileBlobOptions{Root: t.TempDir(), BaseURL: "http://files"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]string{
+		"tts/word/1.wav":                  "RIFF",
+		"tts/word/1.json":                 `{"text":"一","provider":"azure"}`,
+		"tenants/acme/tts/sentence/2.wav": "RIFF",
+		"tts/word/1/versions/old.wav":     "RIFF",
+		"tts/word/3.wav":                  "RIFF",
+		"tts/word/3.json":                 "not json",
+		"other/4.wav":                     "RIFF",
+	} {
+		if err := db.WriteBlob(ctx, name, []byte(data), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := m.Backfill(ctx, db, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (BackfillReport{Listed: 3, Recorded: 2, Failed: 1}) {
+		t.Fatalf("report %+v", report)
+	}
+	expected, err := m.ExpectedClips(ctx, "", "word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []ExpectedClip{{ContextID: 1, Text: "一"}}; !slices.Equal(expected, want) {
+		t.Fatalf("expected clips %+v, want %+v", expected, want)
+	}
+	if missing, _ := m.MissingAudio(ctx, "acme", "sentence", []int{2}); len(missing) != 0 {
+		t.Fatal("clip without metadata was not recorded")
+	}
+
+	report, err = m.Backfill(ctx, db, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (BackfillReport{Listed: 3, Skipped: 2, Failed: 1}) {
+		t.Fatalf("second report %+v", report)
+	}
+}
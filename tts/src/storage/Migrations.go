@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// migrations are applied in order and recorded in tts_schema_migrations. Never
+// edit one that has been released, add a new one instead. {{options}} is
+// replaced with the table options of the driver.
+var migrations = []struct {
+	version    int
+	statements []string
+}{
+	{1, []string{
+		`CREATE TABLE tts_jobs (
+			id VARCHAR(64) NOT NULL PRIMARY KEY,
+			kind VARCHAR(16) NOT NULL,
+			engine VARCHAR(32) NOT NULL,
+			caller VARCHAR(128) NOT NULL,
+			status VARCHAR(16) NOT NULL,
+			words INT NOT NULL,
+			error_message TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			finished_at BIGINT NULL
+		){{options}}`,
+		`CREATE INDEX tts_jobs_status ON tts_jobs (status, created_at)`,
+		`CREATE TABLE tts_job_words (
+			job_id VARCHAR(64) NOT NULL,
+			word_index INT NOT NULL,
+			context_id BIGINT NOT NULL,
+			status VARCHAR(16) NOT NULL,
+			url TEXT NOT NULL,
+			error_message TEXT NOT NULL,
+			PRIMARY KEY (job_id, word_index)
+		){{options}}`,
+		`CREATE INDEX tts_job_words_context ON tts_job_words (context_id)`,
+		`CREATE TABLE tts_clips (
+			path VARCHAR(255) NOT NULL PRIMARY KEY,
+			kind VARCHAR(16) NOT NULL,
+			context_id BIGINT NOT NULL,
+			url TEXT NOT NULL,
+			provider VARCHAR(32) NOT NULL,
+			voice VARCHAR(128) NOT NULL,
+			ssml_sha256 CHAR(64) NOT NULL,
+			duration_seconds DOUBLE NOT NULL,
+			metadata TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		){{options}}`,
+		`CREATE INDEX tts_clips_context ON tts_clips (kind, context_id)`,
+	}},
+	// The text of each job word, so words that never got audio can be synthesized again
+	{2, []string{
+		`ALTER TABLE tts_job_words ADD COLUMN word_text TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE tts_job_words ADD COLUMN pronunciation TEXT NOT NULL DEFAULT ''`,
+	}},
+	// Namespaces, the default one being ''
+	{3, []string{
+		`ALTER TABLE tts_jobs ADD COLUMN namespace VARCHAR(64) NOT NULL DEFAULT ''`,
+		`ALTER TABLE tts_clips ADD COLUMN namespace VARCHAR(64) NOT NULL DEFAULT ''`,
+		`CREATE INDEX tts_clips_namespace ON tts_clips (namespace, kind, context_id)`,
+	}},
+}
+
+func (m *Manifest) migrate(ctx context.Context) error {
+	create := `CREATE TABLE IF NOT EXISTS tts_schema_migrations (
+		version INT NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)` + m.tableOptions()
+	if _, err := m.db.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	row := m.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM tts_schema_migrations")
+	if err := row.Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, migration := range migrations {
+		if migration.version <= current {
+			continue
+		}
+		if err := m.apply(ctx, migration.version, migration.statements); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", migration.version, err)
+		}
+	}
+	return nil
+}
+
+// apply runs one migration in a transaction. MariaDB commits DDL implicitly,
+// so a migration that fails there half way has to be repaired by hand.
+func (m *Manifest) apply(ctx context.Context, version int, statements []string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, strings.ReplaceAll(statement, "{{options}}", m.tableOptions())); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO tts_schema_migrations (version, applied_at) VALUES (?, ?)",
+		version, time.Now().UnixMilli()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tableOptions makes MariaDB store text as full UTF-8, which the Chinese words need
+func (m *Manifest) tableOptions() string {
+	if m.driver == DriverMySQL {
+		return " ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"tts/src/config"
+)
+
+// OpenConfig opens the blob storage the service is configured with
+func OpenConfig(cfg config.StorageConfig) (BlobDatabase, error) {
+	switch cfg.Backend {
+	case "filesystem":
+		return NewFileBlobDatabase(FileBlobOptions{
+			Root:    cfg.Filesystem.Root,
+			BaseURL: cfg.Filesystem.BaseURL,
+		})
+	default:
+		return NewAzureBlobDatabase(AzureBlobOptions{
+			AccountName:   cfg.Azure.AccountName,
+			AccountKey:    cfg.Azure.AccountKey,
+			BlobURL:       cfg.Azure.BlobURL,
+			ContainerName: cfg.Azure.ContainerName,
+			PublicBlobURL: cfg.Azure.PublicBlobURL,
+		})
+	}
+}
+
+// OpenURL opens the blob storage named by a URL, for tools that work on more
+// than one store at a time:
+//
+//	azure://<container>?account=<name>&endpoint=<blob url>&key_env=<variable>
+//	file:///<root>?base_url=<url>
+//
+// Azure settings left out fall back to AZURE_STORAGE_ACCOUNT_NAME,
+// AZURE_STORAGE_BLOB_URL and AZURE_STORAGE_ACCOUNT_KEY, then to Azurite.
+// Keys are only read from the environment so they stay out of shell history.
+func OpenURL(raw string) (BlobDatabase, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage url %q: %w", raw, err)
+	}
+	query := u.Query()
+
+	switch u.Scheme {
+	case "azure":
+		keyEnv := query.Get("key_env")
+		if keyEnv == "" {
+			keyEnv = "AZURE_STORAGE_ACCOUNT_KEY"
+		}
+		return NewAzureBlobDatabase(AzureBlobOptions{
+			AccountName:   firstNonEmpty(query.Get("account"), os.Getenv("AZURE_STORAGE_ACCOUNT_NAME")),
+			AccountKey:    os.Getenv(keyEnv),
+			BlobURL:       firstNonEmpty(query.Get("endpoint"), os.Getenv("AZURE_STORAGE_BLOB_URL")),
+			ContainerName: u.Host,
+		})
+	case "file":
+		return NewFileBlobDatabase(FileBlobOptions{Root: u.Host + u.Path, BaseURL: query.Get("base_url")})
+	default:
+		return nil, fmt.Errorf("unsupported storage url %q, expected azure:// or file://", raw)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/namespace"
+	"tts/src/tts"
+)
+
+// versionFormat sorts lexically in time order, so listings come back oldest first
+const versionFormat = "20060102T150405.000000000Z"
+
+var versionPattern = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z$`)
+
+// ClipMetadata records what produced a stored clip
+type ClipMetadata struct {
+	Provider        string        `json:"provider"`
+	Voice           string        `json:"voice"`
+	SSMLHash        string        `json:"ssml_sha256"`
+	Config          tts.TTSConfig `json:"config"`
+	RatePercent     *float64      `json:"rate_percent,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	Text            string        `json:"text"`
+	Pronunciation   string        `json:"pronunciation"`
+	SoftwareVersion string        `json:"software_version"`
+	// RestoredFrom is the version a rollback copied this clip from. CreatedAt
+	// is then the time of the rollback.
+	RestoredFrom string `json:"restored_from,omitempty"`
+}
+
+// ClipVersion is one entry of a clip's history
+type ClipVersion struct {
+	Version  string        `json:"version"`
+	Current  bool          `json:"current"`
+	Size     int64         `json:"size"`
+	Metadata *ClipMetadata `json:"metadata,omitempty"`
+}
+
+// ClipStore writes clips together with a metadata sidecar and copies the clip
+// being replaced into its history, pruning it to the newest keepVersions
+// entries in the background.
+//
+// For tts/word/42.wav the sidecar is tts/word/42.json and earlier versions
+// live under tts/word/42/versions/, and likewise in other namespaces.
+//
+// When a manifest is given every saved and deleted clip is recorded in it too.
+// Failing to do so only logs a warning, since the blobs are the source of truth.
+type ClipStore struct {
+	db           BlobDatabase
+	keepVersions int
+	manifest     *Manifest
+
+	// pruning holds the paths waiting to be pruned, pruneSlots bounds how many
+	// prunes list and delete at once
+	pruning    sync.Map
+	pruneSlots chan struct{}
+}
+
+// pruneConcurrency is how many clip histories are pruned at the same time
+const pruneConcurrency = 4
+
+func NewClipStore(db BlobDatabase, keepVersions int, manifest *Manifest) *ClipStore {
+	return &ClipStore{db: db, keepVersions: keepVersions, manifest: manifest, pruneSlots: make(chan struct{}, pruneConcurrency)}
+}
+
+// Save stores audio and its metadata under path, archiving the previous clip first
+func (s *ClipStore) Save(ctx context.Context, path string, audio []byte, metadata ClipMetadata) (string, error) {
+	if s.keepVersions > 0 {
+		if err := s.archive(ctx, path); err != nil {
+			return "", err
+		}
+	}
+
+	url, err := s.db.InsertTTSAudio(path, audio)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode clip metadata: %w", err)
+	}
+	if err := s.db.WriteBlob(ctx, metadataPath(path), data, "application/json"); err != nil {
+		// The old sidecar would otherwise describe the new audio, and the next
+		// archive would file it under the old version's name. Without one the
+		// version is taken from the audio's modification time.
+		if removeErr := s.db.DeleteTTSAudio(ctx, metadataPath(path)); removeErr != nil && !IsNotFound(removeErr) {
+			logging.FromContext(ctx).Error("stale clip metadata left in place", "path", path, "error", removeErr)
+		}
+		return "", err
+	}
+	if ns, kind, _, ok := ParseClipPath(path); ok {
+		metrics.ClipStored(namespace.Label(ns), kind)
+	}
+
+	if s.manifest != nil {
+		if err := s.manifest.SaveClip(ctx, path, url, metadata); err != nil {
+			logging.FromContext(ctx).Warn("manifest is out of date", "path", path, "error", err)
+		}
+	}
+	return url, nil
+}
+
+// Metadata returns the metadata recorded for the current clip, or nil for
+// clips stored before metadata was kept
+func (s *ClipStore) Metadata(ctx context.Context, path string) (*ClipMetadata, error) {
+	return s.readMetadata(ctx, metadataPath(path))
+}
+
+// History lists the current clip followed by earlier versions, newest first
+func (s *ClipStore) History(ctx context.Context, path string) ([]ClipVersion, error) {
+	var history []ClipVersion
+
+	current, err := s.db.OpenTTSAudio(ctx, path)
+	switch {
+	case err == nil:
+		current.Close()
+		metadata, err := s.Metadata(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, ClipVersion{
+			Version:  versionOf(metadata, current.Properties().LastModified),
+			Current:  true,
+			Size:     current.Properties().Size,
+			Metadata: metadata,
+		})
+	case !IsNotFound(err):
+		return nil, err
+	}
+
+	versions, err := s.versions(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		metadata, err := s.readMetadata(ctx, versionPath(path, version.Version, ".json"))
+		if err != nil {
+			return nil, err
+		}
+		version.Metadata = metadata
+		history = append(history, version)
+	}
+
+	if len(history) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	return history, nil
+}
+
+// Rollback makes an earlier version the current clip. The clip it replaces is
+// archived like any other overwrite, so a rollback can itself be undone.
+func (s *ClipStore) Rollback(ctx context.Context, path, version string) (string, *ClipMetadata, error) {
+	if !versionPattern.MatchString(version) {
+		return "", nil, fmt.Errorf("%w: version %q", ErrNotFound, version)
+	}
+
+	audio, err := s.db.ReadBlob(ctx, versionPath(path, version, ".wav"))
+	if err != nil {
+		return "", nil, err
+	}
+	metadata, err := s.readMetadata(ctx, versionPath(path, version, ".json"))
+	if err != nil {
+		return "", nil, err
+	}
+	if metadata == nil {
+		metadata = &ClipMetadata{DurationSeconds: tts.AudioDuration(audio)}
+	}
+	metadata.RestoredFrom = version
+	metadata.CreatedAt = time.Now().UTC()
+
+	url, err := s.Save(ctx, path, audio, *metadata)
+	if err != nil {
+		return "", nil, err
+	}
+	return url, metadata, nil
+}
+
+// Delete removes the current clip and its metadata. History is kept so the
+// clip can still be rolled back.
+func (s *ClipStore) Delete(ctx context.Context, path string) error {
+	if s.keepVersions > 0 {
+		if err := s.archive(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.DeleteTTSAudio(ctx, path); err != nil {
+		return err
+	}
+	if err := s.db.DeleteTTSAudio(ctx, metadataPath(path)); err != nil && !IsNotFound(err) {
+		return err
+	}
+
+	if s.manifest != nil {
+		if err := s.manifest.DeleteClip(ctx, path); err != nil {
+			logging.FromContext(ctx).Warn("manifest is out of date", "path", path, "error", err)
+		}
+	}
+	return nil
+}
+
+// archive copies the current clip and its metadata into the history within
+// the store, then drops the oldest versions beyond keepVersions in the
+// background. A clip with a sidecar costs a read and two copies; a path
+// without one costs a properties request, which finds most new clips absent.
+func (s *ClipStore) archive(ctx context.Context, path string) error {
+	metadata, err := s.Metadata(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	var lastModified time.Time
+	if metadata == nil || metadata.CreatedAt.IsZero() {
+		current, err := s.db.OpenTTSAudio(ctx, path)
+		if err != nil {
+			if IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		current.Close()
+		lastModified = current.Properties().LastModified
+	}
+	version := versionOf(metadata, lastModified)
+
+	if err := s.db.CopyBlob(ctx, path, versionPath(path, version, ".wav")); err != nil {
+		if IsNotFound(err) {
+			// A sidecar left without its clip, nothing to keep
+			return nil
+		}
+		return fmt.Errorf("failed to archive clip: %w", err)
+	}
+	if metadata != nil {
+		if err := s.db.CopyBlob(ctx, metadataPath(path), versionPath(path, version, ".json")); err != nil {
+			return fmt.Errorf("failed to archive clip metadata: %w", err)
+		}
+	}
+
+	s.pruneLater(ctx, path)
+	return nil
+}
+
+// pruneLater prunes the history of path off the request path. Prunes of the
+// same clip that are already waiting are not repeated, and one that fails is
+// caught up with by the next save.
+func (s *ClipStore) pruneLater(ctx context.Context, path string) {
+	if _, pending := s.pruning.LoadOrStore(path, true); pending {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		s.pruneSlots <- struct{}{}
+		defer func() { <-s.pruneSlots }()
+		s.pruning.Delete(path)
+
+		if err := s.prune(ctx, path); err != nil {
+			logging.FromContext(ctx).Warn("failed to prune clip history", "path", path, "error", err)
+		}
+	}()
+}
+
+func (s *ClipStore) prune(ctx context.Context, path string) error {
+	versions, err := s.versions(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	for len(versions) > s.keepVersions {
+		oldest := versions[0].Version
+		for _, ext := range []string{".wav", ".json"} {
+			err := s.db.DeleteTTSAudio(ctx, versionPath(path, oldest, ext))
+			if err != nil && !IsNotFound(err) {
+				return fmt.Errorf("failed to prune version %s: %w", oldest, err)
+			}
+		}
+		versions = versions[1:]
+	}
+	return nil
+}
+
+// versions lists the archived versions of a clip, oldest first, without metadata
+func (s *ClipStore) versions(ctx context.Context, path string) ([]ClipVersion, error) {
+	items, err := s.db.ListBlobs(ctx, versionsPrefix(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []ClipVersion
+	for _, item := range items {
+		name := strings.TrimPrefix(item.Name, versionsPrefix(path))
+		if !strings.HasSuffix(name, ".wav") {
+			continue
+		}
+		versions = append(versions, ClipVersion{
+			Version: strings.TrimSuffix(name, ".wav"),
+			Size:    item.Size,
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version < versions[j].Version
+	})
+	return versions, nil
+}
+
+func (s *ClipStore) readMetadata(ctx context.Context, name string) (*ClipMetadata, error) {
+	data, err := s.db.ReadBlob(ctx, name)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var metadata ClipMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode clip metadata %s: %w", name, err)
+	}
+	return &metadata, nil
+}
+
+// versionOf names a version after the time its clip was created, falling back
+// to the blob's modification time for clips stored without metadata
+func versionOf(metadata *ClipMetadata, lastModified time.Time) string {
+	if metadata != nil && !metadata.CreatedAt.IsZero() {
+		return metadata.CreatedAt.UTC().Format(versionFormat)
+	}
+	return lastModified.UTC().Format(versionFormat)
+}
+
+func metadataPath(path string) string {
+	return strings.TrimSuffix(path, ".wav") + ".json"
+}
+
+func versionsPrefix(path string) string {
+	return strings.TrimSuffix(path, ".wav") + "/versions/"
+}
+
+func versionPath(path, version, ext string) string {
+	return versionsPrefix(path) + version + ext
+}
@@ -0,0 +1,62 @@
+package synthesis
+
+import (
+	"container/list"
+	"sync"
+)
+
+type cacheEntry struct {
+	key   string
+	audio []byte
+}
+
+// AudioCache is a fixed size LRU cache of synthesized audio
+type AudioCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewAudioCache returns a cache holding up to size clips. A size of zero disables caching.
+func NewAudioCache(size int) *AudioCache {
+	return &AudioCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *AudioCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).audio, true
+}
+
+func (c *AudioCache) Add(key string, audio []byte) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*cacheEntry).audio = audio
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, audio: audio})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
@@ -0,0 +1,399 @@
+package synthesis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tts/src/accounting"
+	"tts/src/config"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"tts/src/namespace"
+	"tts/src/storage"
+	"tts/src/tts"
+)
+
+// ErrNoSegment fails words left over when splitting finds fewer segments than words
+var ErrNoSegment = errors.New("no audio segment was split out for this word")
+
+// ErrUnknownVoice rejects a requested voice the engine is not configured with
+var ErrUnknownVoice = errors.New("voice is not configured for this engine")
+
+// Engine synthesizes words with one provider and stores the clips
+type Engine struct {
+	ttsProvider  tts.TTSProvider
+	ttsConfig    tts.TTSConfig
+	clips        *storage.ClipStore
+	previewCache *AudioCache
+	voices       map[string]bool
+	opts         Options
+}
+
+type Options struct {
+	// Version identifies the build in clip metadata
+	Version string
+	// ClipURL returns the URL handed out for a clip just stored at path, nil
+	// hands out the URL the store returned
+	ClipURL func(ctx context.Context, path, stored string) string
+}
+
+// NewEngine creates the engine for one provider from the loaded configuration
+func NewEngine(provider string, cfg *config.Config, clips *storage.ClipStore, opts Options) (*Engine, error) {
+	configuration := tts.TTSConfig{
+		BreakDurationMs: cfg.Synthesis.BreakDurationMs,
+		SilenceThreshDB: cfg.Synthesis.SilenceThreshDB,
+		MinSilenceLen:   cfg.Synthesis.MinSilenceLen,
+		KeepSilence:     cfg.Synthesis.KeepSilence,
+		SeekStep:        cfg.Synthesis.SeekStep,
+	}
+
+	var ttsProvider tts.TTSProvider
+	var voices []string
+	var err error
+
+	switch provider {
+	case "google":
+		google := cfg.Providers.Google
+		voices = google.Voices
+		ttsProvider, err = tts.NewGoogleTTSProvider(google.LanguageCode, google.MaleVoice, google.FemaleVoice,
+			google.CredentialsFile, configuration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize google tts %v", err)
+		}
+	case "azure":
+		azure := cfg.Providers.Azure
+		voices = azure.Voices
+		ttsProvider, err = tts.NewAzureTTSProvider(azure.LanguageCode, azure.MaleVoice, azure.FemaleVoice,
+			azure.APIKey, azure.Region, configuration)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize azure tts %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid tts provider")
+	}
+	e := &Engine{
+		ttsProvider:  ttsProvider,
+		ttsConfig:    configuration,
+		clips:        clips,
+		previewCache: NewAudioCache(cfg.Preview.CacheEntries),
+		voices:       make(map[string]bool),
+		opts:         opts,
+	}
+	for _, voice := range append(voices, slices.Collect(maps.Values(ttsProvider.Voices()))...) {
+		e.voices[voice] = true
+	}
+
+	return e, nil
+}
+
+// Stages a word passes through in a batch. Every word ends uploaded or failed.
+const (
+	StageQueued      = "queued"
+	StageSynthesized = "synthesized"
+	StageSplit       = "split"
+	StageUploaded    = "uploaded"
+	StageFailed      = "failed"
+)
+
+// WordProgress reports that the word at Index of a batch reached Stage
+type WordProgress struct {
+	Index int
+	Stage string
+	URL   string
+	Error string
+}
+
+// ProgressFunc is called from the goroutine running a batch as its words progress
+type ProgressFunc func(WordProgress)
+
+// BatchProcessWords synthesizes words in a single provider request, splits the
+// result on the breaks between words and uploads one clip per word. The URLs
+// are in word order; when an upload fails, those uploaded before it are returned
+// with the error. progress may be nil.
+func (e *Engine) BatchProcessWords(ctx context.Context, words []tts.Word, gender string, sentence bool, progress ProgressFunc) ([]string, error) {
+	if progress == nil {
+		progress = func(WordProgress) {}
+	}
+
+	provider := e.ttsProvider.Name()
+
+	voice, err := e.ttsProvider.Voice(gender)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = logging.With(ctx, "provider", provider, "voice", voice, "words", len(words))
+	logger := logging.FromContext(ctx)
+
+	options := tts.SynthesisOptions{}
+	ssmlHash := hashSSML(e.ttsProvider.SSML(words, voice, options))
+	audio, err := e.ttsProvider.Synthesize(ctx, words, voice, options)
+	if err != nil {
+		return nil, err
+	}
+	// The whole batch is a single provider request, so every word is synthesized at once
+	for i := range words {
+		progress(WordProgress{Index: i, Stage: StageSynthesized})
+	}
+
+	chunks, err := tts.SplitOnSilence(e.ttsConfig, audio)
+	if err != nil {
+		metrics.Error(metrics.ErrorSplit)
+		return nil, fmt.Errorf("failed to split audio: %w", err)
+	}
+
+	metrics.ObserveSplit(provider, len(chunks), len(words))
+	if len(chunks) != len(words) {
+		logger.Warn("chunk count does not match word count", "chunks", len(chunks))
+	}
+	if len(chunks) > len(words) {
+		chunks = chunks[:len(words)]
+	}
+	for i := range words {
+		if i < len(chunks) {
+			progress(WordProgress{Index: i, Stage: StageSplit})
+		} else {
+			progress(WordProgress{Index: i, Stage: StageFailed, Error: ErrNoSegment.Error()})
+		}
+	}
+
+	// Upload each audio chunk to blob storage.
+	uploadStart := time.Now()
+	var urls []string
+	for i, chunk := range chunks {
+		metadata := e.clipMetadata(words[i], voice, ssmlHash, options, chunk.Data)
+		path := AudioPath(ctx, strconv.Itoa(words[i].Id), sentence)
+		url, err := e.clips.Save(ctx, path, chunk.Data, metadata)
+		if err != nil {
+			metrics.Error(metrics.ErrorUpload)
+			err = fmt.Errorf("failed to upload chunk %d: %w", i, err)
+			progress(WordProgress{Index: i, Stage: StageFailed, Error: err.Error()})
+			return urls, err
+		}
+		url = e.clipURL(ctx, path, url)
+		urls = append(urls, url)
+		progress(WordProgress{Index: i, Stage: StageUploaded, URL: url})
+	}
+	logger.Info("uploaded audio", "chunks", len(urls), "upload_ms", time.Since(uploadStart).Milliseconds())
+
+	return urls, nil
+}
+
+// CheckVoice returns ErrUnknownVoice unless voice is empty, one of the
+// provider's gendered voices or one of the extra voices configured for it
+func (e *Engine) CheckVoice(voice string) error {
+	if voice == "" || e.voices[voice] {
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownVoice, voice)
+}
+
+// Preview synthesizes a single word or sentence and returns it trimmed of
+// surrounding silence, without storing it. An empty voice picks one by gender.
+func (e *Engine) Preview(ctx context.Context, word tts.Word, voice, gender string, options tts.SynthesisOptions) ([]byte, error) {
+	if err := e.CheckVoice(voice); err != nil {
+		return nil, err
+	}
+	if voice == "" {
+		var err error
+		voice, err = e.ttsProvider.Voice(gender)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key := previewCacheKey(namespace.FromContext(ctx), e.ttsProvider.Name(), voice, word, options)
+	if audio, ok := e.previewCache.Get(key); ok {
+		metrics.CacheHit("preview", true)
+		return audio, nil
+	}
+	metrics.CacheHit("preview", false)
+
+	return e.synthesizeTrimmed(ctx, key, word, voice, options)
+}
+
+// synthesizeTrimmed always asks the provider for word, trims the audio and
+// stores it in the preview cache under key, replacing any earlier audio
+func (e *Engine) synthesizeTrimmed(ctx context.Context, key string, word tts.Word, voice string, options tts.SynthesisOptions) ([]byte, error) {
+	ctx = logging.With(ctx, "provider", e.ttsProvider.Name(), "voice", voice, "words", 1)
+
+	audio, err := e.ttsProvider.Synthesize(ctx, []tts.Word{word}, voice, options)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed, err := tts.TrimSilence(e.ttsConfig, audio)
+	if err != nil {
+		metrics.Error(metrics.ErrorSplit)
+		return nil, fmt.Errorf("failed to trim audio: %w", err)
+	}
+
+	e.previewCache.Add(key, trimmed)
+	return trimmed, nil
+}
+
+// PreviewStream synthesizes a single word or sentence and returns the audio as it
+// arrives from the provider. Streamed audio is neither trimmed nor cached.
+func (e *Engine) PreviewStream(ctx context.Context, word tts.Word, voice, gender string, options tts.SynthesisOptions) (io.ReadCloser, error) {
+	if err := e.CheckVoice(voice); err != nil {
+		return nil, err
+	}
+	if voice == "" {
+		var err error
+		voice, err = e.ttsProvider.Voice(gender)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = logging.With(ctx, "provider", e.ttsProvider.Name(), "voice", voice, "words", 1)
+	return e.ttsProvider.SynthesizeStream(ctx, []tts.Word{word}, voice, options)
+}
+
+// RegenerateResult is the outcome of regenerating one clip
+type RegenerateResult struct {
+	Id     int    `json:"context_id"`
+	Status string `json:"status"`
+	URL    string `json:"url,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Regenerate re-synthesizes each word on its own, bypassing the preview cache,
+// and replaces its stored clip. A clip is only overwritten once its new audio
+// has been produced, so words that fail keep their previous audio. When the
+// character budget runs out the remaining words fail too and the error wraps
+// accounting.ErrBudgetExceeded. There is a result for every word. progress may be nil.
+func (e *Engine) Regenerate(ctx context.Context, words []tts.Word, voice, gender string, sentence bool, options tts.SynthesisOptions, progress ProgressFunc) ([]RegenerateResult, error) {
+	if progress == nil {
+		progress = func(WordProgress) {}
+	}
+
+	results := make([]RegenerateResult, 0, len(words))
+	var budgetErr error
+	for i, word := range words {
+		result := RegenerateResult{Id: word.Id}
+
+		// Once the budget is spent the remaining words are not attempted
+		err := budgetErr
+		skipped := err != nil
+		wordVoice := voice
+		if err == nil {
+			err = e.CheckVoice(voice)
+		}
+		if err == nil && wordVoice == "" {
+			wordVoice, err = e.ttsProvider.Voice(gender)
+		}
+
+		var audio []byte
+		if err == nil {
+			key := previewCacheKey(namespace.FromContext(ctx), e.ttsProvider.Name(), wordVoice, word, options)
+			audio, err = e.synthesizeTrimmed(ctx, key, word, wordVoice, options)
+			if errors.Is(err, accounting.ErrBudgetExceeded) {
+				budgetErr = err
+			}
+		}
+		if err == nil {
+			progress(WordProgress{Index: i, Stage: StageSynthesized})
+			ssmlHash := hashSSML(e.ttsProvider.SSML([]tts.Word{word}, wordVoice, options))
+			metadata := e.clipMetadata(word, wordVoice, ssmlHash, options, audio)
+			path := AudioPath(ctx, strconv.Itoa(word.Id), sentence)
+			result.URL, err = e.clips.Save(ctx, path, audio, metadata)
+			if err != nil {
+				metrics.Error(metrics.ErrorUpload)
+			}
+			result.URL = e.clipURL(ctx, path, result.URL)
+		}
+
+		if err != nil {
+			if !skipped {
+				logging.FromContext(ctx).Error("failed to regenerate audio", "id", word.Id, "error", err)
+			}
+			result.Status = "failed"
+			result.Error = err.Error()
+			progress(WordProgress{Index: i, Stage: StageFailed, Error: result.Error})
+		} else {
+			result.Status = "regenerated"
+			progress(WordProgress{Index: i, Stage: StageUploaded, URL: result.URL})
+		}
+		results = append(results, result)
+	}
+	return results, budgetErr
+}
+
+// clipMetadata records how a stored clip was produced
+func (e *Engine) clipMetadata(word tts.Word, voice, ssmlHash string, options tts.SynthesisOptions, audio []byte) storage.ClipMetadata {
+	return storage.ClipMetadata{
+		Provider:        e.ttsProvider.Name(),
+		Voice:           voice,
+		SSMLHash:        ssmlHash,
+		Config:          e.ttsConfig,
+		RatePercent:     options.RatePercent,
+		CreatedAt:       time.Now().UTC(),
+		DurationSeconds: tts.AudioDuration(audio),
+		Text:            word.Text,
+		Pronunciation:   word.Pronunciation,
+		SoftwareVersion: e.opts.Version,
+	}
+}
+
+func hashSSML(ssml string) string {
+	sum := sha256.Sum256([]byte(ssml))
+	return hex.EncodeToString(sum[:])
+}
+
+// previewCacheKey keeps each namespace's previews apart, like its stored clips
+func previewCacheKey(ns, provider, voice string, word tts.Word, options tts.SynthesisOptions) string {
+	rate := "default"
+	if options.RatePercent != nil {
+		rate = fmt.Sprintf("%.2f", *options.RatePercent)
+	}
+	return strings.Join([]string{ns, provider, voice, rate, word.Text, word.Pronunciation}, "\x00")
+}
+
+// clipURL is the URL handed out for the clip just stored at path
+func (e *Engine) clipURL(ctx context.Context, path, stored string) string {
+	if e.opts.ClipURL == nil {
+		return stored
+	}
+	return e.opts.ClipURL(ctx, path, stored)
+}
+
+// ClipKind names the kind of clip in jobs and the manifest
+func ClipKind(sentence bool) string {
+	if sentence {
+		return "sentence"
+	}
+	return "word"
+}
+
+// AudioPath is the blob name a word or sentence clip of the request's namespace is stored under
+func AudioPath(ctx context.Context, id string, sentence bool) string {
+	return storage.ClipPath(namespace.FromContext(ctx), ClipKind(sentence), id)
+}
+
+// Name is the provider this engine synthesizes with
+func (e *Engine) Name() string {
+	return e.ttsProvider.Name()
+}
+
+// Voices returns the configured voice for each gender
+func (e *Engine) Voices() map[string]string {
+	return e.ttsProvider.Voices()
+}
+
+// CheckCredentials verifies the provider credentials are usable
+func (e *Engine) CheckCredentials(ctx context.Context) error {
+	return e.ttsProvider.CheckCredentials(ctx)
+}
+
+func (e *Engine) Close() {
+
+}
@@ -12,34 +12,70 @@ type AudioSegment struct {
 	Channels   int
 }
 
-func splitOnSilence(config TTSConfig, audioData []byte) ([]AudioSegment, error) {
+// SampleRange is a stretch of audio in samples, End excluded
+type SampleRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// SilenceAnalysis is what SplitOnSilence detects in a clip: the silent
+// regions long enough to split on, and the segments it cuts between them
+type SilenceAnalysis struct {
+	SampleRate int           `json:"sample_rate"`
+	Samples    int           `json:"samples"`
+	Silences   []SampleRange `json:"silences"`
+	Segments   []SampleRange `json:"segments"`
+}
+
+// SplitOnSilence cuts batch audio into one segment per stretch of speech, keeping
+// KeepSilence ms of the surrounding silence on each side. The audio is raw PCM
+// or, as Google returns it, WAV, whose header is skipped rather than read as samples.
+func SplitOnSilence(config TTSConfig, audioData []byte) ([]AudioSegment, error) {
 	sampleRate := 24000
 	channels := 1
 
-	minSilenceLen := config.MinSilenceLen
-
-	silenceThresh := int16(math.Pow(10, config.SilenceThreshDB/20) * 32768)
-	minSilenceSamples := minSilenceLen * sampleRate / 1000
-	keepSilenceSamples := config.KeepSilence * sampleRate / 1000
-	seekStepSamples := config.SeekStep
+	pcm := audioData
+	if len(audioData) >= 44 && string(audioData[0:4]) == "RIFF" && string(audioData[8:12]) == "WAVE" {
+		if rate := int(binary.LittleEndian.Uint32(audioData[24:28])); rate > 0 {
+			sampleRate = rate
+		}
+		pcm = audioData[44:]
+	}
 
-	samples := make([]int16, len(audioData)/2)
-	if err := binary.Read(bytes.NewReader(audioData), binary.LittleEndian, &samples); err != nil {
+	samples := make([]int16, len(pcm)/2)
+	if err := binary.Read(bytes.NewReader(pcm[:len(samples)*2]), binary.LittleEndian, &samples); err != nil {
 		return nil, err
 	}
 
-	silentRegions := []struct{ start, end int }{}
+	analysis := AnalyzeSilence(config, samples, sampleRate)
+	if len(analysis.Silences) == 0 {
+		wavData := addWAVHeader(pcm, sampleRate, channels)
+		return []AudioSegment{{Data: wavData, SampleRate: sampleRate, Channels: channels}}, nil
+	}
+
+	var chunks []AudioSegment
+	for _, segment := range analysis.Segments {
+		wavData := createWAV(samples[segment.Start:segment.End], sampleRate, channels)
+		chunks = append(chunks, AudioSegment{Data: wavData, SampleRate: sampleRate, Channels: channels})
+	}
+	return chunks, nil
+}
+
+// AnalyzeSilence finds the silent regions of mono audio that last at least
+// MinSilenceLen ms, checking every SeekStep samples, and the segments between
+// them padded with KeepSilence ms. Without silences the whole clip is one segment.
+func AnalyzeSilence(config TTSConfig, samples []int16, sampleRate int) SilenceAnalysis {
+	silenceThresh := int16(math.Pow(10, config.SilenceThreshDB/20) * 32768)
+	minSilenceSamples := config.MinSilenceLen * sampleRate / 1000
+	keepSilenceSamples := config.KeepSilence * sampleRate / 1000
+	seekStepSamples := max(config.SeekStep, 1)
+
+	analysis := SilenceAnalysis{SampleRate: sampleRate, Samples: len(samples), Silences: []SampleRange{}}
 	inSilence := false
 	silenceStart := 0
 
-	for i := 0; i < len(samples); i += channels * seekStepSamples {
-		var sum int64
-		for ch := 0; ch < channels; ch++ {
-			if i+ch < len(samples) {
-				sum += int64(math.Abs(float64(samples[i+ch])))
-			}
-		}
-		avgAmplitude := int16(sum / int64(channels))
+	for i := 0; i < len(samples); i += seekStepSamples {
+		avgAmplitude := int16(int64(math.Abs(float64(samples[i]))))
 
 		if avgAmplitude <= silenceThresh {
 			if !inSilence {
@@ -47,59 +83,92 @@ func splitOnSilence(config TTSConfig, audioData []byte) ([]AudioSegment, error)
 				silenceStart = i
 			}
 		} else if inSilence {
-			if silenceLen := i - silenceStart; silenceLen >= minSilenceSamples*channels {
-				silentRegions = append(silentRegions, struct{ start, end int }{silenceStart, i})
+			if silenceLen := i - silenceStart; silenceLen >= minSilenceSamples {
+				analysis.Silences = append(analysis.Silences, SampleRange{silenceStart, i})
 			}
 			inSilence = false
 		}
 	}
 
-	if inSilence && (len(samples)-silenceStart) >= minSilenceSamples*channels {
-		silentRegions = append(silentRegions, struct{ start, end int }{silenceStart, len(samples)})
+	if inSilence && (len(samples)-silenceStart) >= minSilenceSamples {
+		analysis.Silences = append(analysis.Silences, SampleRange{silenceStart, len(samples)})
 	}
 
-	if len(silentRegions) == 0 {
-		wavData := addWAVHeader(audioData, sampleRate, channels)
-		return []AudioSegment{{Data: wavData, SampleRate: sampleRate, Channels: channels}}, nil
+	silences := analysis.Silences
+	if len(silences) == 0 {
+		analysis.Segments = []SampleRange{{0, len(samples)}}
+		return analysis
 	}
 
-	var chunks []AudioSegment
+	if silences[0].Start > 0 {
+		analysis.Segments = append(analysis.Segments, SampleRange{0, min(silences[0].Start+keepSilenceSamples, len(samples))})
+	}
+	for i := 0; i < len(silences)-1; i++ {
+		analysis.Segments = append(analysis.Segments, SampleRange{
+			max(silences[i].End-keepSilenceSamples, 0),
+			min(silences[i+1].Start+keepSilenceSamples, len(samples)),
+		})
+	}
+	if lastEnd := silences[len(silences)-1].End; lastEnd < len(samples) {
+		analysis.Segments = append(analysis.Segments, SampleRange{max(lastEnd-keepSilenceSamples, 0), len(samples)})
+	}
+	if analysis.Segments == nil {
+		analysis.Segments = []SampleRange{}
+	}
+	return analysis
+}
 
-	if silentRegions[0].start > 0 {
-		end := silentRegions[0].start + keepSilenceSamples*channels
-		if end > len(samples) {
-			end = len(samples)
+// EncodeWAV wraps 16-bit mono samples in a WAV header
+func EncodeWAV(samples []int16, sampleRate int) []byte {
+	return createWAV(samples, sampleRate, 1)
+}
+
+// TrimSilence removes leading and trailing silence from WAV audio, keeping
+// KeepSilence ms of padding on each side
+func TrimSilence(config TTSConfig, audioData []byte) ([]byte, error) {
+	sampleRate := 24000
+	channels := 1
+
+	pcm := audioData
+	if len(audioData) >= 44 && string(audioData[0:4]) == "RIFF" && string(audioData[8:12]) == "WAVE" {
+		if rate := int(binary.LittleEndian.Uint32(audioData[24:28])); rate > 0 {
+			sampleRate = rate
 		}
-		chunk := samples[:end]
-		wavData := createWAV(chunk, sampleRate, channels)
-		chunks = append(chunks, AudioSegment{Data: wavData, SampleRate: sampleRate, Channels: channels})
+		pcm = audioData[44:]
 	}
 
-	for i := 0; i < len(silentRegions)-1; i++ {
-		start := silentRegions[i].end - keepSilenceSamples*channels
-		if start < 0 {
-			start = 0
-		}
-		end := silentRegions[i+1].start + keepSilenceSamples*channels
-		if end > len(samples) {
-			end = len(samples)
-		}
-		chunk := samples[start:end]
-		wavData := createWAV(chunk, sampleRate, channels)
-		chunks = append(chunks, AudioSegment{Data: wavData, SampleRate: sampleRate, Channels: channels})
+	samples := make([]int16, len(pcm)/2)
+	if err := binary.Read(bytes.NewReader(pcm[:len(samples)*2]), binary.LittleEndian, &samples); err != nil {
+		return nil, err
 	}
 
-	if lastEnd := silentRegions[len(silentRegions)-1].end; lastEnd < len(samples) {
-		start := lastEnd - keepSilenceSamples*channels
-		if start < 0 {
-			start = 0
+	silenceThresh := math.Pow(10, config.SilenceThreshDB/20) * 32768
+	keepSilenceSamples := config.KeepSilence * sampleRate / 1000
+
+	first, last := -1, -1
+	for i, sample := range samples {
+		if math.Abs(float64(sample)) > silenceThresh {
+			if first < 0 {
+				first = i
+			}
+			last = i
 		}
-		chunk := samples[start:]
-		wavData := createWAV(chunk, sampleRate, channels)
-		chunks = append(chunks, AudioSegment{Data: wavData, SampleRate: sampleRate, Channels: channels})
 	}
 
-	return chunks, nil
+	if first < 0 {
+		return createWAV(nil, sampleRate, channels), nil
+	}
+
+	start := first - keepSilenceSamples
+	if start < 0 {
+		start = 0
+	}
+	end := last + 1 + keepSilenceSamples
+	if end > len(samples) {
+		end = len(samples)
+	}
+
+	return createWAV(samples[start:end], sampleRate, channels), nil
 }
 
 func createWAV(samples []int16, sampleRate, channels int) []byte {
@@ -125,3 +194,28 @@ func addWAVHeader(pcmData []byte, sampleRate, channels int) []byte {
 
 	return append(header.Bytes(), pcmData...)
 }
+
+// WAVDuration returns the length in seconds of a WAV clip of size bytes in the
+// format clips are stored in, 24 kHz 16-bit mono, without reading it
+func WAVDuration(size int64) float64 {
+	if size <= 44 {
+		return 0
+	}
+	return float64(size-44) / (24000 * 2)
+}
+
+// AudioDuration returns the length in seconds of 16-bit PCM audio, reading the
+// byte rate from the WAV header when there is one
+func AudioDuration(audioData []byte) float64 {
+	byteRate := 24000 * 2
+	dataLen := len(audioData)
+
+	if len(audioData) >= 44 && string(audioData[0:4]) == "RIFF" && string(audioData[8:12]) == "WAVE" {
+		if rate := int(binary.LittleEndian.Uint32(audioData[28:32])); rate > 0 {
+			byteRate = rate
+		}
+		dataLen -= 44
+	}
+
+	return float64(dataLen) / float64(byteRate)
+}
@@ -2,15 +2,16 @@ package tts
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
-	"os"
 	"regexp"
-	"strings"
 	"time"
-	"tts/src/storage"
+	"tts/src/accounting"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"unicode/utf8"
 )
 
 type AzureTTSProvider struct {
@@ -18,23 +19,15 @@ type AzureTTSProvider struct {
 	maleVoice    string
 	femaleVoice  string
 	ttsConfig    TTSConfig
-	blobDatabase storage.BlobDatabase
 	azureKey     string
 	azureRegion  string
 }
 
-func NewAzureTTSProvider(languageCode, maleVoice, femaleVoice string, config TTSConfig) (*AzureTTSProvider, error) {
-	blobDB, err := storage.ConnectToAzurite()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to blob storage: %w", err)
-	}
-
-	azureKey := os.Getenv("AZURE_API_KEY")
+func NewAzureTTSProvider(languageCode, maleVoice, femaleVoice, azureKey, azureRegion string, config TTSConfig) (*AzureTTSProvider, error) {
 	if azureKey == "" {
 		return nil, fmt.Errorf("invalid azure api key")
 	}
 
-	azureRegion := os.Getenv("AZURE_REGION")
 	if azureRegion == "" {
 		return nil, fmt.Errorf("invalid azure region")
 	}
@@ -44,118 +37,191 @@ func NewAzureTTSProvider(languageCode, maleVoice, femaleVoice string, config TTS
 		maleVoice:    maleVoice,
 		femaleVoice:  femaleVoice,
 		ttsConfig:    config,
-		blobDatabase: blobDB,
 		azureKey:     azureKey,
 		azureRegion:  azureRegion,
 	}, nil
 }
 
-func (a *AzureTTSProvider) Process(words []Word, gender string, sentence bool) ([]string, error) {
-	var voice string
-	switch strings.ToLower(gender) {
-	case "male":
-		voice = a.maleVoice
-	case "female":
-		voice = a.femaleVoice
-	case "any":
-		rand.Seed(time.Now().UnixNano())
-		flip := rand.Intn(2)
-
-		if flip == 0 {
-			voice = a.maleVoice
-		} else {
-			voice = a.femaleVoice
-		}
-	default:
-		return nil, fmt.Errorf("invalid gender provided: %s", gender)
-	}
+func (a *AzureTTSProvider) Name() string {
+	return "azure"
+}
 
-	// Synthesize speech using Azure REST API.
-	audio, err := a.synthesizeSpeech(words, voice)
+func (a *AzureTTSProvider) Voice(gender string) (string, error) {
+	return pickVoice(gender, a.maleVoice, a.femaleVoice)
+}
+
+func (a *AzureTTSProvider) Voices() map[string]string {
+	return map[string]string{"male": a.maleVoice, "female": a.femaleVoice}
+}
+
+// Synthesize speaks words using the Azure REST API.
+func (a *AzureTTSProvider) Synthesize(ctx context.Context, words []Word, voice string, options SynthesisOptions) ([]byte, error) {
+	audio, err := a.synthesizeSpeech(ctx, words, voice, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to synthesize speech: %w", err)
 	}
+	return audio, nil
+}
 
-	// Split audio into chunks. This reuses the splitting logic (e.g. splitOnSilence) shared with Google.
-	chunks, err := splitOnSilence(a.ttsConfig, audio)
+// SynthesizeStream speaks words and returns the audio as it arrives from Azure,
+// as a WAV stream whose header does not declare a length
+func (a *AzureTTSProvider) SynthesizeStream(ctx context.Context, words []Word, voice string, options SynthesisOptions) (io.ReadCloser, error) {
+	ssml := a.SSML(words, voice, options)
+
+	logger := logging.FromContext(ctx)
+	logger.Debug("generated ssml", "ssml", ssml)
+
+	billable := accounting.BillableCharacters("azure", ssml)
+	reservation, err := accounting.Default().Check(ctx, billable)
 	if err != nil {
-		return nil, fmt.Errorf("failed to split audio: %w", err)
+		return nil, err
 	}
 
-	if len(chunks) != len(words) {
-		fmt.Printf("Warning: %d chunks for %d words\n", len(chunks), len(words))
+	metrics.ProviderCharacters.WithLabelValues("azure", voice).Add(float64(utf8.RuneCountInString(ssml)))
+	start := time.Now()
+
+	resp, err := a.post(ctx, ssml, "raw-24khz-16bit-mono-pcm")
+	if err != nil {
+		metrics.ObserveProvider("azure", voice, start, err)
+		logger.Error("synthesis failed", "duration_ms", time.Since(start).Milliseconds(), "error", err)
+		accounting.Default().Release(reservation)
+		return nil, fmt.Errorf("failed to synthesize speech: %w", err)
 	}
+	metrics.ProviderFirstByte.WithLabelValues("azure", voice).Observe(time.Since(start).Seconds())
 
-	// Upload each audio chunk to blob storage.
-	var urls []string
-	for i, chunk := range chunks {
-		path := ""
-		if sentence {
-			path = "tts/sentence/"
-		} else {
-			path = "tts/word/"
+	return newAudioStream(resp.Body, 24000, 1, func(pcmBytes int64, err error) {
+		metrics.ObserveProvider("azure", voice, start, err)
+		if err != nil {
+			metrics.Error(metrics.ErrorProviderNetwork)
+			logger.Error("synthesis stream failed", "duration_ms", time.Since(start).Milliseconds(), "error", err)
+			accounting.Default().Release(reservation)
+			return
 		}
-		fileName := fmt.Sprintf("%s%d.wav", path, words[i].Id)
-		url, err := a.blobDatabase.InsertTTSAudio(fileName, chunk.Data)
+		seconds := float64(pcmBytes) / (24000 * 2)
+		logger.Info("streamed speech", "duration_ms", time.Since(start).Milliseconds(), "bytes", pcmBytes)
+		accounting.Default().Record(ctx, reservation, "azure", voice, seconds)
+	}), nil
+}
+
+// synthesizeSpeech builds an SSML payload, calls the Azure TTS REST API, and returns the raw audio bytes.
+func (a *AzureTTSProvider) synthesizeSpeech(ctx context.Context, words []Word, voice string, options SynthesisOptions) (audioData []byte, err error) {
+	ssml := a.SSML(words, voice, options)
+
+	logger := logging.FromContext(ctx)
+	logger.Debug("generated ssml", "ssml", ssml)
+
+	billable := accounting.BillableCharacters("azure", ssml)
+	reservation, err := accounting.Default().Check(ctx, billable)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.ProviderCharacters.WithLabelValues("azure", voice).Add(float64(utf8.RuneCountInString(ssml)))
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProvider("azure", voice, start, err)
 		if err != nil {
-			return nil, fmt.Errorf("failed to upload chunk %d: %w", i, err)
+			logger.Error("synthesis failed", "duration_ms", time.Since(start).Milliseconds(), "error", err)
+			accounting.Default().Release(reservation)
+		} else {
+			logger.Info("synthesized speech", "duration_ms", time.Since(start).Milliseconds(), "bytes", len(audioData))
+			accounting.Default().Record(ctx, reservation, "azure", voice, AudioDuration(audioData))
 		}
-		urls = append(urls, url)
+	}()
+
+	resp, err := a.post(ctx, ssml, "riff-24khz-16bit-mono-pcm")
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	return urls, nil
+	audioData, err = io.ReadAll(resp.Body)
+	if err != nil {
+		metrics.Error(metrics.ErrorProviderNetwork)
+		return nil, err
+	}
+
+	return audioData, nil
 }
 
-// synthesizeSpeech builds an SSML payload, calls the Azure TTS REST API, and returns the raw audio bytes.
-func (a *AzureTTSProvider) synthesizeSpeech(words []Word, voice string) ([]byte, error) {
+// SSML builds the request body for words, with a break after each one
+func (a *AzureTTSProvider) SSML(words []Word, voice string, options SynthesisOptions) string {
+	rate := -20.0
+	if options.RatePercent != nil {
+		rate = *options.RatePercent
+	}
+
 	ssml := fmt.Sprintf(`<speak xmlns='http://www.w3.org/2001/10/synthesis' xmlns:mstts='http://www.w3.org/2001/mstts' xmlns:emo='http://www.w3.org/2009/10/emotionml' version='1.0' xml:lang='%s'>
 		<voice name='%s'>
-			<lang xml:lang="%s"><prosody rate='-20.00%%' pitch='default' contour="">`, a.languageCode, voice, a.languageCode)
+			<lang xml:lang="%s"><prosody rate='%+.2f%%' pitch='default' contour="">`, a.languageCode, escapeXML(voice), a.languageCode, rate)
 
 	for _, word := range words {
 		if word.Pronunciation != "" {
 			ssml += fmt.Sprintf(`<phoneme alphabet='sapi' ph='%s'>%s</phoneme><break time='%dms'/>`,
-				addSpaceBeforeNumbers(word.Pronunciation), word.Text, a.ttsConfig.BreakDurationMs)
+				escapeXML(addSpaceBeforeNumbers(word.Pronunciation)), escapeXML(word.Text), a.ttsConfig.BreakDurationMs)
 		} else {
-			ssml += fmt.Sprintf("%s<break time='%dms'/>", word.Text, a.ttsConfig.BreakDurationMs)
+			ssml += fmt.Sprintf("%s<break time='%dms'/>", escapeXML(word.Text), a.ttsConfig.BreakDurationMs)
 		}
 	}
 
 	ssml += `</prosody></lang></voice></speak>`
+	return ssml
+}
 
-	fmt.Println("Generated SSML:") // Add this line
-	fmt.Println(ssml)              // Add this line
-
+// post sends ssml to the Azure TTS REST API and returns the successful response.
+// The caller must close the body.
+func (a *AzureTTSProvider) post(ctx context.Context, ssml, outputFormat string) (*http.Response, error) {
 	url := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", a.azureRegion)
 
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(ssml))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBufferString(ssml))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/ssml+xml")
 	req.Header.Set("Ocp-Apim-Subscription-Key", a.azureKey)
-	req.Header.Set("X-Microsoft-OutputFormat", "riff-24khz-16bit-mono-pcm")
+	req.Header.Set("X-Microsoft-OutputFormat", outputFormat)
 	req.Header.Set("User-Agent", "tts")
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
+		metrics.Error(metrics.ErrorProviderNetwork)
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		metrics.Error(metrics.ProviderStatusError(resp.StatusCode))
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("azure TTS API returned status: %d, body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
-	audioData, err := io.ReadAll(resp.Body)
+	return resp, nil
+}
+
+// CheckCredentials mints an access token with the subscription key, which fails
+// for invalid keys or regions without being billed
+func (a *AzureTTSProvider) CheckCredentials(ctx context.Context) error {
+	url := fmt.Sprintf("https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken", a.azureRegion)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.azureKey)
 
-	return audioData, nil
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure token endpoint returned status: %d", resp.StatusCode)
+	}
+
+	return nil
 }
 
 func addSpaceBeforeNumbers(input string) string {
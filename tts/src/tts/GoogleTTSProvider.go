@@ -7,78 +7,70 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
-	"tts/src/storage"
+	"tts/src/accounting"
+	"tts/src/logging"
+	"tts/src/metrics"
+	"unicode/utf8"
 
 	"golang.org/x/oauth2/google"
 )
 
 type GoogleTTSProvider struct {
-	languageCode string
-	maleVoice    string
-	femaleVoice  string
-	ttsConfig    TTSConfig
-	blobDatabase storage.BlobDatabase
-	accessToken  string
+	languageCode    string
+	maleVoice       string
+	femaleVoice     string
+	ttsConfig       TTSConfig
+	credentialsFile string
+
+	mu          sync.Mutex
+	accessToken string
 }
 
-func NewGoogleTTSProvider(languageCode, maleVoice, femaleVoice string, config TTSConfig) (*GoogleTTSProvider, error) {
-	blobDB, err := storage.ConnectToAzurite()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to blob storage: %w", err)
-	}
-
+func NewGoogleTTSProvider(languageCode, maleVoice, femaleVoice, credentialsFile string, config TTSConfig) (*GoogleTTSProvider, error) {
 	return &GoogleTTSProvider{
-		languageCode: languageCode,
-		maleVoice:    maleVoice,
-		femaleVoice:  femaleVoice,
-		ttsConfig:    config,
-		blobDatabase: blobDB,
+		languageCode:    languageCode,
+		maleVoice:       maleVoice,
+		femaleVoice:     femaleVoice,
+		ttsConfig:       config,
+		credentialsFile: credentialsFile,
 	}, nil
 }
 
-func (g *GoogleTTSProvider) Process(words []Word, gender string, sentence bool) ([]string, error) {
-	if g.accessToken == "" {
-		token, err := g.getAccessToken()
-		if err != nil {
-			return nil, err
-		}
-		g.accessToken = token
-	}
+func (g *GoogleTTSProvider) Name() string {
+	return "google"
+}
 
-	var voice string
-	switch strings.ToLower(gender) {
-	case "male":
-		voice = g.maleVoice
-	case "female":
-		voice = g.femaleVoice
-	case "any":
-		rand.Seed(time.Now().UnixNano())
-		flip := rand.Intn(2)
+func (g *GoogleTTSProvider) Voice(gender string) (string, error) {
+	return pickVoice(gender, g.maleVoice, g.femaleVoice)
+}
 
-		if flip == 0 {
-			voice = g.maleVoice
-		} else {
-			voice = g.femaleVoice
-		}
-	default:
-		return nil, fmt.Errorf("invalid gender provided: %s", gender)
+func (g *GoogleTTSProvider) Voices() map[string]string {
+	return map[string]string{"male": g.maleVoice, "female": g.femaleVoice}
+}
+
+// Synthesize speaks words using the Google REST API, refreshing the access token once if it was rejected
+func (g *GoogleTTSProvider) Synthesize(ctx context.Context, words []Word, voice string, options SynthesisOptions) ([]byte, error) {
+	accessToken, err := g.cachedAccessToken()
+	if err != nil {
+		return nil, err
 	}
 
-	audio, err := g.synthesizeSpeech(words, voice, g.accessToken)
+	audio, err := g.synthesizeSpeech(ctx, words, voice, accessToken, options)
 	if err != nil {
 		if strings.Contains(err.Error(), "401") || strings.Contains(err.Error(), "403") {
+			logging.FromContext(ctx).Info("refreshing google access token")
 			token, tokenErr := g.getAccessToken()
 			if tokenErr != nil {
 				return nil, tokenErr
 			}
-			g.accessToken = token
+			g.setAccessToken(token)
 
-			audio, err = g.synthesizeSpeech(words, voice, g.accessToken)
+			audio, err = g.synthesizeSpeech(ctx, words, voice, token, options)
 			if err != nil {
 				return nil, fmt.Errorf("failed after token refresh: %w", err)
 			}
@@ -87,43 +79,40 @@ func (g *GoogleTTSProvider) Process(words []Word, gender string, sentence bool)
 		}
 	}
 
-	chunks, err := splitOnSilence(g.ttsConfig, audio)
-	if err != nil {
-		return nil, fmt.Errorf("split error: %w", err)
-	}
-
-	if len(chunks) != len(words) {
-		fmt.Printf("Warning: %d chunks for %d words\n", len(chunks), len(words))
-	}
+	return audio, nil
+}
 
-	var urls []string
-	for i, chunk := range chunks {
-		path := ""
-		if sentence {
-			path = "tts/sentence/"
-		} else {
-			path = "tts/word/"
-		}
-		fileName := fmt.Sprintf("%s%d.wav", path, words[i].Id)
-		url, err := g.blobDatabase.InsertTTSAudio(fileName, chunk.Data)
-		if err != nil {
-			return nil, fmt.Errorf("upload failed %d: %w", i, err)
-		}
-		urls = append(urls, url)
+// SynthesizeStream returns the synthesized audio as a stream. The Google REST API
+// only answers with the complete clip, so this does not arrive any sooner than Synthesize.
+func (g *GoogleTTSProvider) SynthesizeStream(ctx context.Context, words []Word, voice string, options SynthesisOptions) (io.ReadCloser, error) {
+	audio, err := g.Synthesize(ctx, words, voice, options)
+	if err != nil {
+		return nil, err
 	}
-
-	return urls, nil
+	return io.NopCloser(bytes.NewReader(audio)), nil
 }
 
-func (g *GoogleTTSProvider) synthesizeSpeech(words []Word, voice, accessToken string) ([]byte, error) {
+// SSML builds the request input for words, with a break between each one. The
+// speaking rate is sent in the audio config rather than the markup.
+func (g *GoogleTTSProvider) SSML(words []Word, voice string, options SynthesisOptions) string {
 	var ssmlParts []string
 	for _, word := range words {
 		ssmlParts = append(ssmlParts, fmt.Sprintf(`<phoneme alphabet="pinyin" ph="%s">%s</phoneme><break time="%dms"/>`,
-			word.Pronunciation, word.Text, g.ttsConfig.BreakDurationMs))
+			escapeXML(word.Pronunciation), escapeXML(word.Text), g.ttsConfig.BreakDurationMs))
 	}
 	ssmlText := "<speak>" + strings.Join(ssmlParts, "") + "</speak>"
 	ssmlText = strings.Replace(ssmlText, fmt.Sprintf(`<break time="%dms"/></speak>`,
 		g.ttsConfig.BreakDurationMs), "</speak>", 1)
+	return ssmlText
+}
+
+func (g *GoogleTTSProvider) synthesizeSpeech(ctx context.Context, words []Word, voice, accessToken string, options SynthesisOptions) (audio []byte, err error) {
+	ssmlText := g.SSML(words, voice, options)
+
+	audioConfig := map[string]interface{}{"audioEncoding": "LINEAR16"}
+	if options.RatePercent != nil {
+		audioConfig["speakingRate"] = 1 + *options.RatePercent/100
+	}
 
 	requestBody := map[string]interface{}{
 		"input": map[string]string{"ssml": ssmlText},
@@ -131,7 +120,7 @@ func (g *GoogleTTSProvider) synthesizeSpeech(words []Word, voice, accessToken st
 			"languageCode": g.languageCode,
 			"name":         voice,
 		},
-		"audioConfig": map[string]string{"audioEncoding": "LINEAR16"},
+		"audioConfig": audioConfig,
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
@@ -139,6 +128,28 @@ func (g *GoogleTTSProvider) synthesizeSpeech(words []Word, voice, accessToken st
 		return nil, err
 	}
 
+	logger := logging.FromContext(ctx)
+	logger.Debug("generated ssml", "ssml", ssmlText)
+
+	billable := accounting.BillableCharacters("google", ssmlText)
+	reservation, err := accounting.Default().Check(ctx, billable)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.ProviderCharacters.WithLabelValues("google", voice).Add(float64(utf8.RuneCountInString(ssmlText)))
+	start := time.Now()
+	defer func() {
+		metrics.ObserveProvider("google", voice, start, err)
+		if err != nil {
+			logger.Error("synthesis failed", "duration_ms", time.Since(start).Milliseconds(), "error", err)
+			accounting.Default().Release(reservation)
+		} else {
+			logger.Info("synthesized speech", "duration_ms", time.Since(start).Milliseconds(), "bytes", len(audio))
+			accounting.Default().Record(ctx, reservation, "google", voice, AudioDuration(audio))
+		}
+	}()
+
 	req, err := http.NewRequest("POST", "https://texttospeech.googleapis.com/v1/text:synthesize", bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, err
@@ -148,30 +159,66 @@ func (g *GoogleTTSProvider) synthesizeSpeech(words []Word, voice, accessToken st
 
 	resp, err := (&http.Client{}).Do(req)
 	if err != nil {
+		metrics.Error(metrics.ErrorProviderNetwork)
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		metrics.Error(metrics.ProviderStatusError(resp.StatusCode))
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, body)
 	}
 
 	var responseJson map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&responseJson); err != nil {
+		metrics.Error(metrics.ErrorProviderDecode)
 		return nil, err
 	}
 
 	audioContent, ok := responseJson["audioContent"].(string)
 	if !ok {
+		metrics.Error(metrics.ErrorProviderDecode)
 		return nil, fmt.Errorf("invalid response")
 	}
 
 	return base64.StdEncoding.DecodeString(audioContent)
 }
 
+// CheckCredentials mints a fresh access token from the service account
+func (g *GoogleTTSProvider) CheckCredentials(ctx context.Context) error {
+	token, err := g.getAccessToken()
+	if err != nil {
+		return err
+	}
+	g.setAccessToken(token)
+	return nil
+}
+
+// cachedAccessToken returns the last minted token, minting one if there is none
+func (g *GoogleTTSProvider) cachedAccessToken() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	metrics.CacheHit("google_token", g.accessToken != "")
+	if g.accessToken == "" {
+		token, err := g.getAccessToken()
+		if err != nil {
+			return "", err
+		}
+		g.accessToken = token
+	}
+	return g.accessToken, nil
+}
+
+func (g *GoogleTTSProvider) setAccessToken(token string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.accessToken = token
+}
+
 func (g *GoogleTTSProvider) getAccessToken() (string, error) {
-	data, err := os.ReadFile("/config/google_service.json")
+	data, err := os.ReadFile(g.credentialsFile)
 	if err != nil {
 		return "", fmt.Errorf("service account read error: %w", err)
 	}
@@ -0,0 +1,80 @@
+package tts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// unknownLength is written to the RIFF and data size fields of streamed WAV
+// audio, which players treat as "read until the stream ends"
+const unknownLength = 0xFFFFFFFF
+
+// streamingWAVHeader returns a 16-bit PCM WAV header that does not declare a length
+func streamingWAVHeader(sampleRate, channels int) []byte {
+	header := bytes.NewBuffer(nil)
+	header.Write([]byte("RIFF"))
+	binary.Write(header, binary.LittleEndian, uint32(unknownLength)) // ChunkSize
+	header.Write([]byte("WAVEfmt "))
+	binary.Write(header, binary.LittleEndian, uint32(16)) // Subchunk1Size
+	binary.Write(header, binary.LittleEndian, uint16(1))  // AudioFormat
+	binary.Write(header, binary.LittleEndian, uint16(channels))
+	binary.Write(header, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(header, binary.LittleEndian, uint32(sampleRate*channels*2)) // ByteRate
+	binary.Write(header, binary.LittleEndian, uint16(channels*2))            // BlockAlign
+	binary.Write(header, binary.LittleEndian, uint16(16))                    // BitsPerSample
+	header.Write([]byte("data"))
+	binary.Write(header, binary.LittleEndian, uint32(unknownLength)) // Subchunk2Size
+
+	return header.Bytes()
+}
+
+// audioStream prefixes raw PCM from a provider with a streaming WAV header and
+// reports how much PCM was read once the provider body is finished
+type audioStream struct {
+	io.Reader
+	body   io.ReadCloser
+	read   int64
+	once   sync.Once
+	onDone func(pcmBytes int64, err error)
+}
+
+func newAudioStream(body io.ReadCloser, sampleRate, channels int, onDone func(pcmBytes int64, err error)) io.ReadCloser {
+	s := &audioStream{body: body, onDone: onDone}
+	s.Reader = io.MultiReader(bytes.NewReader(streamingWAVHeader(sampleRate, channels)), readerFunc(s.readBody))
+	return s
+}
+
+func (s *audioStream) readBody(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	s.read += int64(n)
+	if errors.Is(err, io.EOF) {
+		s.finish(nil)
+	} else if err != nil {
+		s.finish(err)
+	}
+	return n, err
+}
+
+// Close releases the provider response. Audio the client stopped reading was
+// still synthesized, so it is reported as a successful request.
+func (s *audioStream) Close() error {
+	s.finish(nil)
+	return s.body.Close()
+}
+
+func (s *audioStream) finish(err error) {
+	s.once.Do(func() {
+		if s.onDone != nil {
+			s.onDone(s.read, err)
+		}
+	})
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
@@ -1,21 +1,77 @@
 package tts
 
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+// PronunciationPattern matches numbered pinyin such as "ni3 hao3", the only
+// form of pronunciation the providers' phoneme tags accept
+const PronunciationPattern = `^[A-Za-zÜüVv:]+[1-5]?( *[A-Za-zÜüVv:]+[1-5]?)*$`
+
 // Word represents a word with its pronunciation
 type Word struct {
-	Id            int    `json:"context_id"`
-	Text          string `json:"text"`
-	Pronunciation string `json:"pronunciation"`
+	Id            int    `json:"context_id" binding:"gte=0"`
+	Text          string `json:"text" binding:"required"`
+	Pronunciation string `json:"pronunciation" binding:"omitempty,pinyin"`
 }
 
 // TTSConfig holds configuration for TTS
 type TTSConfig struct {
-	BreakDurationMs int
-	SilenceThreshDB float64
-	MinSilenceLen   int
-	KeepSilence     int
-	SeekStep        int
+	BreakDurationMs int     `json:"break_duration_ms"`
+	SilenceThreshDB float64 `json:"silence_thresh_db"`
+	MinSilenceLen   int     `json:"min_silence_len_ms"`
+	KeepSilence     int     `json:"keep_silence_ms"`
+	SeekStep        int     `json:"seek_step"`
+}
+
+// SynthesisOptions tweak how a provider speaks. Zero values keep the provider defaults.
+type SynthesisOptions struct {
+	// RatePercent speeds up (positive) or slows down (negative) speech relative to normal
+	RatePercent *float64
 }
 
 type TTSProvider interface {
-	Process(words []Word, gender string, sentence bool) ([]string, error)
+	// Name identifies the provider in logs, metrics and accounting
+	Name() string
+	// Voice picks the configured voice for "male", "female" or "any"
+	Voice(gender string) (string, error)
+	// Voices returns the configured voice for each gender
+	Voices() map[string]string
+	// Synthesize speaks words with a break after each one and returns 24kHz 16-bit mono WAV audio
+	Synthesize(ctx context.Context, words []Word, voice string, options SynthesisOptions) ([]byte, error)
+	// SynthesizeStream is Synthesize returning audio as the provider produces it. The
+	// WAV header may not declare a length, so readers must consume until EOF.
+	SynthesizeStream(ctx context.Context, words []Word, voice string, options SynthesisOptions) (io.ReadCloser, error)
+	// SSML returns the markup Synthesize sends for words, so stored clips can record what produced them
+	SSML(words []Word, voice string, options SynthesisOptions) string
+	// CheckCredentials verifies the provider will accept requests without synthesizing anything
+	CheckCredentials(ctx context.Context) error
+}
+
+func pickVoice(gender, maleVoice, femaleVoice string) (string, error) {
+	switch strings.ToLower(gender) {
+	case "male":
+		return maleVoice, nil
+	case "female":
+		return femaleVoice, nil
+	case "any":
+		if rand.Intn(2) == 0 {
+			return maleVoice, nil
+		}
+		return femaleVoice, nil
+	default:
+		return "", fmt.Errorf("invalid gender provided: %s", gender)
+	}
+}
+
+// escapeXML makes client supplied text safe inside SSML elements and attributes
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
 }
@@ -0,0 +1,4 @@
+// Package ttspb holds the gRPC service generated from proto/tts/v1/tts.proto
+package ttspb
+
+//go:generate sh -c "cd ../.. && buf generate"
@@ -0,0 +1,994 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: tts/v1/tts.proto
+
+package ttspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ClipKind int32
+
+const (
+	ClipKind_CLIP_KIND_UNSPECIFIED ClipKind = 0
+	ClipKind_CLIP_KIND_WORD        ClipKind = 1
+	ClipKind_CLIP_KIND_SENTENCE    ClipKind = 2
+	// Only the kinds of jobs sweeping stored clips, never of clips themselves
+	ClipKind_CLIP_KIND_RECONCILE ClipKind = 3
+	ClipKind_CLIP_KIND_RETENTION ClipKind = 4
+)
+
+// Enum value maps for ClipKind.
+var (
+	ClipKind_name = map[int32]string{
+		0: "CLIP_KIND_UNSPECIFIED",
+		1: "CLIP_KIND_WORD",
+		2: "CLIP_KIND_SENTENCE",
+		3: "CLIP_KIND_RECONCILE",
+		4: "CLIP_KIND_RETENTION",
+	}
+	ClipKind_value = map[string]int32{
+		"CLIP_KIND_UNSPECIFIED": 0,
+		"CLIP_KIND_WORD":        1,
+		"CLIP_KIND_SENTENCE":    2,
+		"CLIP_KIND_RECONCILE":   3,
+		"CLIP_KIND_RETENTION":   4,
+	}
+)
+
+func (x ClipKind) Enum() *ClipKind {
+	p := new(ClipKind)
+	*p = x
+	return p
+}
+
+func (x ClipKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClipKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_tts_v1_tts_proto_enumTypes[0].Descriptor()
+}
+
+func (ClipKind) Type() protoreflect.EnumType {
+	return &file_tts_v1_tts_proto_enumTypes[0]
+}
+
+func (x ClipKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ClipKind.Descriptor instead.
+func (ClipKind) EnumDescriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{0}
+}
+
+type Word struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ContextId int64                  `protobuf:"varint,1,opt,name=context_id,json=contextId,proto3" json:"context_id,omitempty"`
+	Text      string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// Numbered pinyin such as "ni3 hao3"
+	Pronunciation string `protobuf:"bytes,3,opt,name=pronunciation,proto3" json:"pronunciation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Word) Reset() {
+	*x = Word{}
+	mi := &file_tts_v1_tts_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Word) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Word) ProtoMessage() {}
+
+func (x *Word) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Word.ProtoReflect.Descriptor instead.
+func (*Word) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Word) GetContextId() int64 {
+	if x != nil {
+		return x.ContextId
+	}
+	return 0
+}
+
+func (x *Word) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Word) GetPronunciation() string {
+	if x != nil {
+		return x.Pronunciation
+	}
+	return ""
+}
+
+type ProcessRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Kind  ClipKind               `protobuf:"varint,1,opt,name=kind,proto3,enum=tts.v1.ClipKind" json:"kind,omitempty"`
+	// "azure" or "google", empty for the default engine
+	Engine string `protobuf:"bytes,2,opt,name=engine,proto3" json:"engine,omitempty"`
+	// "male", "female" or "any", empty for any
+	Gender        string  `protobuf:"bytes,3,opt,name=gender,proto3" json:"gender,omitempty"`
+	Words         []*Word `protobuf:"bytes,4,rep,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProcessRequest) Reset() {
+	*x = ProcessRequest{}
+	mi := &file_tts_v1_tts_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProcessRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessRequest) ProtoMessage() {}
+
+func (x *ProcessRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessRequest.ProtoReflect.Descriptor instead.
+func (*ProcessRequest) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProcessRequest) GetKind() ClipKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ClipKind_CLIP_KIND_UNSPECIFIED
+}
+
+func (x *ProcessRequest) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *ProcessRequest) GetGender() string {
+	if x != nil {
+		return x.Gender
+	}
+	return ""
+}
+
+func (x *ProcessRequest) GetWords() []*Word {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+type SynthesizeRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Engine string                 `protobuf:"bytes,1,opt,name=engine,proto3" json:"engine,omitempty"`
+	Gender string                 `protobuf:"bytes,2,opt,name=gender,proto3" json:"gender,omitempty"`
+	// A provider voice name, overriding gender
+	Voice         string `protobuf:"bytes,3,opt,name=voice,proto3" json:"voice,omitempty"`
+	Text          string `protobuf:"bytes,4,opt,name=text,proto3" json:"text,omitempty"`
+	Pronunciation string `protobuf:"bytes,5,opt,name=pronunciation,proto3" json:"pronunciation,omitempty"`
+	// Speeds up (positive) or slows down (negative) speech, unset for the provider default
+	RatePercent   *float64 `protobuf:"fixed64,6,opt,name=rate_percent,json=ratePercent,proto3,oneof" json:"rate_percent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SynthesizeRequest) Reset() {
+	*x = SynthesizeRequest{}
+	mi := &file_tts_v1_tts_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SynthesizeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SynthesizeRequest) ProtoMessage() {}
+
+func (x *SynthesizeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SynthesizeRequest.ProtoReflect.Descriptor instead.
+func (*SynthesizeRequest) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SynthesizeRequest) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetGender() string {
+	if x != nil {
+		return x.Gender
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetVoice() string {
+	if x != nil {
+		return x.Voice
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetPronunciation() string {
+	if x != nil {
+		return x.Pronunciation
+	}
+	return ""
+}
+
+func (x *SynthesizeRequest) GetRatePercent() float64 {
+	if x != nil && x.RatePercent != nil {
+		return *x.RatePercent
+	}
+	return 0
+}
+
+type AudioChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AudioChunk) Reset() {
+	*x = AudioChunk{}
+	mi := &file_tts_v1_tts_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioChunk) ProtoMessage() {}
+
+func (x *AudioChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioChunk.ProtoReflect.Descriptor instead.
+func (*AudioChunk) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AudioChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type BatchGetAudioRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kind          ClipKind               `protobuf:"varint,1,opt,name=kind,proto3,enum=tts.v1.ClipKind" json:"kind,omitempty"`
+	ContextIds    []int64                `protobuf:"varint,2,rep,packed,name=context_ids,json=contextIds,proto3" json:"context_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetAudioRequest) Reset() {
+	*x = BatchGetAudioRequest{}
+	mi := &file_tts_v1_tts_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetAudioRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetAudioRequest) ProtoMessage() {}
+
+func (x *BatchGetAudioRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetAudioRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetAudioRequest) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetAudioRequest) GetKind() ClipKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ClipKind_CLIP_KIND_UNSPECIFIED
+}
+
+func (x *BatchGetAudioRequest) GetContextIds() []int64 {
+	if x != nil {
+		return x.ContextIds
+	}
+	return nil
+}
+
+type AudioClip struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ContextId int64                  `protobuf:"varint,1,opt,name=context_id,json=contextId,proto3" json:"context_id,omitempty"`
+	// False when no clip is stored, in which case data is empty
+	Found         bool   `protobuf:"varint,2,opt,name=found,proto3" json:"found,omitempty"`
+	Data          []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
+	Etag          string `protobuf:"bytes,4,opt,name=etag,proto3" json:"etag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AudioClip) Reset() {
+	*x = AudioClip{}
+	mi := &file_tts_v1_tts_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AudioClip) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AudioClip) ProtoMessage() {}
+
+func (x *AudioClip) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AudioClip.ProtoReflect.Descriptor instead.
+func (*AudioClip) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AudioClip) GetContextId() int64 {
+	if x != nil {
+		return x.ContextId
+	}
+	return 0
+}
+
+func (x *AudioClip) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+func (x *AudioClip) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *AudioClip) GetEtag() string {
+	if x != nil {
+		return x.Etag
+	}
+	return ""
+}
+
+type GetJobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetJobRequest) Reset() {
+	*x = GetJobRequest{}
+	mi := &file_tts_v1_tts_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetJobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetJobRequest) ProtoMessage() {}
+
+func (x *GetJobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetJobRequest.ProtoReflect.Descriptor instead.
+func (*GetJobRequest) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetJobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Job struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Kind   ClipKind               `protobuf:"varint,2,opt,name=kind,proto3,enum=tts.v1.ClipKind" json:"kind,omitempty"`
+	Engine string                 `protobuf:"bytes,3,opt,name=engine,proto3" json:"engine,omitempty"`
+	// "queued", "running", "succeeded" or "failed"
+	Status string `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	Words  int32  `protobuf:"varint,5,opt,name=words,proto3" json:"words,omitempty"`
+	Error  string `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+	// Unix milliseconds; finished_at is 0 until the job ends
+	CreatedAt  int64 `protobuf:"varint,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	FinishedAt int64 `protobuf:"varint,8,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	// The outcome of each word once the job has finished. Sweeps have none, their
+	// reports are only served by the REST API.
+	Results       []*WordResult `protobuf:"bytes,9,rep,name=results,proto3" json:"results,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_tts_v1_tts_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetKind() ClipKind {
+	if x != nil {
+		return x.Kind
+	}
+	return ClipKind_CLIP_KIND_UNSPECIFIED
+}
+
+func (x *Job) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *Job) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Job) GetWords() int32 {
+	if x != nil {
+		return x.Words
+	}
+	return 0
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Job) GetFinishedAt() int64 {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return 0
+}
+
+func (x *Job) GetResults() []*WordResult {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+type WordResult struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ContextId int64                  `protobuf:"varint,1,opt,name=context_id,json=contextId,proto3" json:"context_id,omitempty"`
+	// "uploaded" or "failed"
+	Stage         string `protobuf:"bytes,2,opt,name=stage,proto3" json:"stage,omitempty"`
+	Url           string `protobuf:"bytes,3,opt,name=url,proto3" json:"url,omitempty"`
+	Error         string `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WordResult) Reset() {
+	*x = WordResult{}
+	mi := &file_tts_v1_tts_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WordResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WordResult) ProtoMessage() {}
+
+func (x *WordResult) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WordResult.ProtoReflect.Descriptor instead.
+func (*WordResult) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *WordResult) GetContextId() int64 {
+	if x != nil {
+		return x.ContextId
+	}
+	return 0
+}
+
+func (x *WordResult) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *WordResult) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *WordResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type ListVoicesRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only this engine, empty for all enabled engines
+	Engine        string `protobuf:"bytes,1,opt,name=engine,proto3" json:"engine,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVoicesRequest) Reset() {
+	*x = ListVoicesRequest{}
+	mi := &file_tts_v1_tts_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVoicesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVoicesRequest) ProtoMessage() {}
+
+func (x *ListVoicesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVoicesRequest.ProtoReflect.Descriptor instead.
+func (*ListVoicesRequest) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListVoicesRequest) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+type Voice struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Engine        string                 `protobuf:"bytes,1,opt,name=engine,proto3" json:"engine,omitempty"`
+	Gender        string                 `protobuf:"bytes,2,opt,name=gender,proto3" json:"gender,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Voice) Reset() {
+	*x = Voice{}
+	mi := &file_tts_v1_tts_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Voice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Voice) ProtoMessage() {}
+
+func (x *Voice) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Voice.ProtoReflect.Descriptor instead.
+func (*Voice) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Voice) GetEngine() string {
+	if x != nil {
+		return x.Engine
+	}
+	return ""
+}
+
+func (x *Voice) GetGender() string {
+	if x != nil {
+		return x.Gender
+	}
+	return ""
+}
+
+func (x *Voice) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type ListVoicesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Voices        []*Voice               `protobuf:"bytes,1,rep,name=voices,proto3" json:"voices,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListVoicesResponse) Reset() {
+	*x = ListVoicesResponse{}
+	mi := &file_tts_v1_tts_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListVoicesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListVoicesResponse) ProtoMessage() {}
+
+func (x *ListVoicesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tts_v1_tts_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListVoicesResponse.ProtoReflect.Descriptor instead.
+func (*ListVoicesResponse) Descriptor() ([]byte, []int) {
+	return file_tts_v1_tts_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListVoicesResponse) GetVoices() []*Voice {
+	if x != nil {
+		return x.Voices
+	}
+	return nil
+}
+
+var File_tts_v1_tts_proto protoreflect.FileDescriptor
+
+const file_tts_v1_tts_proto_rawDesc = "" +
+	"\n" +
+	"\x10tts/v1/tts.proto\x12\x06tts.v1\"_\n" +
+	"\x04Word\x12\x1d\n" +
+	"\n" +
+	"context_id\x18\x01 \x01(\x03R\tcontextId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12$\n" +
+	"\rpronunciation\x18\x03 \x01(\tR\rpronunciation\"\x8a\x01\n" +
+	"\x0eProcessRequest\x12$\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x10.tts.v1.ClipKindR\x04kind\x12\x16\n" +
+	"\x06engine\x18\x02 \x01(\tR\x06engine\x12\x16\n" +
+	"\x06gender\x18\x03 \x01(\tR\x06gender\x12\"\n" +
+	"\x05words\x18\x04 \x03(\v2\f.tts.v1.WordR\x05words\"\xcc\x01\n" +
+	"\x11SynthesizeRequest\x12\x16\n" +
+	"\x06engine\x18\x01 \x01(\tR\x06engine\x12\x16\n" +
+	"\x06gender\x18\x02 \x01(\tR\x06gender\x12\x14\n" +
+	"\x05voice\x18\x03 \x01(\tR\x05voice\x12\x12\n" +
+	"\x04text\x18\x04 \x01(\tR\x04text\x12$\n" +
+	"\rpronunciation\x18\x05 \x01(\tR\rpronunciation\x12&\n" +
+	"\frate_percent\x18\x06 \x01(\x01H\x00R\vratePercent\x88\x01\x01B\x0f\n" +
+	"\r_rate_percent\" \n" +
+	"\n" +
+	"AudioChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"]\n" +
+	"\x14BatchGetAudioRequest\x12$\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x10.tts.v1.ClipKindR\x04kind\x12\x1f\n" +
+	"\vcontext_ids\x18\x02 \x03(\x03R\n" +
+	"contextIds\"h\n" +
+	"\tAudioClip\x12\x1d\n" +
+	"\n" +
+	"context_id\x18\x01 \x01(\x03R\tcontextId\x12\x14\n" +
+	"\x05found\x18\x02 \x01(\bR\x05found\x12\x12\n" +
+	"\x04data\x18\x03 \x01(\fR\x04data\x12\x12\n" +
+	"\x04etag\x18\x04 \x01(\tR\x04etag\"\x1f\n" +
+	"\rGetJobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x85\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12$\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x10.tts.v1.ClipKindR\x04kind\x12\x16\n" +
+	"\x06engine\x18\x03 \x01(\tR\x06engine\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x14\n" +
+	"\x05words\x18\x05 \x01(\x05R\x05words\x12\x14\n" +
+	"\x05error\x18\x06 \x01(\tR\x05error\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\a \x01(\x03R\tcreatedAt\x12\x1f\n" +
+	"\vfinished_at\x18\b \x01(\x03R\n" +
+	"finishedAt\x12,\n" +
+	"\aresults\x18\t \x03(\v2\x12.tts.v1.WordResultR\aresults\"i\n" +
+	"\n" +
+	"WordResult\x12\x1d\n" +
+	"\n" +
+	"context_id\x18\x01 \x01(\x03R\tcontextId\x12\x14\n" +
+	"\x05stage\x18\x02 \x01(\tR\x05stage\x12\x10\n" +
+	"\x03url\x18\x03 \x01(\tR\x03url\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"+\n" +
+	"\x11ListVoicesRequest\x12\x16\n" +
+	"\x06engine\x18\x01 \x01(\tR\x06engine\"K\n" +
+	"\x05Voice\x12\x16\n" +
+	"\x06engine\x18\x01 \x01(\tR\x06engine\x12\x16\n" +
+	"\x06gender\x18\x02 \x01(\tR\x06gender\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\";\n" +
+	"\x12ListVoicesResponse\x12%\n" +
+	"\x06voices\x18\x01 \x03(\v2\r.tts.v1.VoiceR\x06voices*\x83\x01\n" +
+	"\bClipKind\x12\x19\n" +
+	"\x15CLIP_KIND_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eCLIP_KIND_WORD\x10\x01\x12\x16\n" +
+	"\x12CLIP_KIND_SENTENCE\x10\x02\x12\x17\n" +
+	"\x13CLIP_KIND_RECONCILE\x10\x03\x12\x17\n" +
+	"\x13CLIP_KIND_RETENTION\x10\x042\xb2\x02\n" +
+	"\n" +
+	"TTSService\x12.\n" +
+	"\aProcess\x12\x16.tts.v1.ProcessRequest\x1a\v.tts.v1.Job\x12=\n" +
+	"\n" +
+	"Synthesize\x12\x19.tts.v1.SynthesizeRequest\x1a\x12.tts.v1.AudioChunk0\x01\x12B\n" +
+	"\rBatchGetAudio\x12\x1c.tts.v1.BatchGetAudioRequest\x1a\x11.tts.v1.AudioClip0\x01\x12,\n" +
+	"\x06GetJob\x12\x15.tts.v1.GetJobRequest\x1a\v.tts.v1.Job\x12C\n" +
+	"\n" +
+	"ListVoices\x12\x19.tts.v1.ListVoicesRequest\x1a\x1a.tts.v1.ListVoicesResponseB\x15Z\x13tts/src/ttspb;ttspbb\x06proto3"
+
+var (
+	file_tts_v1_tts_proto_rawDescOnce sync.Once
+	file_tts_v1_tts_proto_rawDescData []byte
+)
+
+func file_tts_v1_tts_proto_rawDescGZIP() []byte {
+	file_tts_v1_tts_proto_rawDescOnce.Do(func() {
+		file_tts_v1_tts_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tts_v1_tts_proto_rawDesc), len(file_tts_v1_tts_proto_rawDesc)))
+	})
+	return file_tts_v1_tts_proto_rawDescData
+}
+
+var file_tts_v1_tts_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_tts_v1_tts_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_tts_v1_tts_proto_goTypes = []any{
+	(ClipKind)(0),                // 0: tts.v1.ClipKind
+	(*Word)(nil),                 // 1: tts.v1.Word
+	(*ProcessRequest)(nil),       // 2: tts.v1.ProcessRequest
+	(*SynthesizeRequest)(nil),    // 3: tts.v1.SynthesizeRequest
+	(*AudioChunk)(nil),           // 4: tts.v1.AudioChunk
+	(*BatchGetAudioRequest)(nil), // 5: tts.v1.BatchGetAudioRequest
+	(*AudioClip)(nil),            // 6: tts.v1.AudioClip
+	(*GetJobRequest)(nil),        // 7: tts.v1.GetJobRequest
+	(*Job)(nil),                  // 8: tts.v1.Job
+	(*WordResult)(nil),           // 9: tts.v1.WordResult
+	(*ListVoicesRequest)(nil),    // 10: tts.v1.ListVoicesRequest
+	(*Voice)(nil),                // 11: tts.v1.Voice
+	(*ListVoicesResponse)(nil),   // 12: tts.v1.ListVoicesResponse
+}
+var file_tts_v1_tts_proto_depIdxs = []int32{
+	0,  // 0: tts.v1.ProcessRequest.kind:type_name -> tts.v1.ClipKind
+	1,  // 1: tts.v1.ProcessRequest.words:type_name -> tts.v1.Word
+	0,  // 2: tts.v1.BatchGetAudioRequest.kind:type_name -> tts.v1.ClipKind
+	0,  // 3: tts.v1.Job.kind:type_name -> tts.v1.ClipKind
+	9,  // 4: tts.v1.Job.results:type_name -> tts.v1.WordResult
+	11, // 5: tts.v1.ListVoicesResponse.voices:type_name -> tts.v1.Voice
+	2,  // 6: tts.v1.TTSService.Process:input_type -> tts.v1.ProcessRequest
+	3,  // 7: tts.v1.TTSService.Synthesize:input_type -> tts.v1.SynthesizeRequest
+	5,  // 8: tts.v1.TTSService.BatchGetAudio:input_type -> tts.v1.BatchGetAudioRequest
+	7,  // 9: tts.v1.TTSService.GetJob:input_type -> tts.v1.GetJobRequest
+	10, // 10: tts.v1.TTSService.ListVoices:input_type -> tts.v1.ListVoicesRequest
+	8,  // 11: tts.v1.TTSService.Process:output_type -> tts.v1.Job
+	4,  // 12: tts.v1.TTSService.Synthesize:output_type -> tts.v1.AudioChunk
+	6,  // 13: tts.v1.TTSService.BatchGetAudio:output_type -> tts.v1.AudioClip
+	8,  // 14: tts.v1.TTSService.GetJob:output_type -> tts.v1.Job
+	12, // 15: tts.v1.TTSService.ListVoices:output_type -> tts.v1.ListVoicesResponse
+	11, // [11:16] is the sub-list for method output_type
+	6,  // [6:11] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_tts_v1_tts_proto_init() }
+func file_tts_v1_tts_proto_init() {
+	if File_tts_v1_tts_proto != nil {
+		return
+	}
+	file_tts_v1_tts_proto_msgTypes[2].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tts_v1_tts_proto_rawDesc), len(file_tts_v1_tts_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tts_v1_tts_proto_goTypes,
+		DependencyIndexes: file_tts_v1_tts_proto_depIdxs,
+		EnumInfos:         file_tts_v1_tts_proto_enumTypes,
+		MessageInfos:      file_tts_v1_tts_proto_msgTypes,
+	}.Build()
+	File_tts_v1_tts_proto = out.File
+	file_tts_v1_tts_proto_goTypes = nil
+	file_tts_v1_tts_proto_depIdxs = nil
+}
@@ -0,0 +1,306 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tts/v1/tts.proto
+
+package ttspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	TTSService_Process_FullMethodName       = "/tts.v1.TTSService/Process"
+	TTSService_Synthesize_FullMethodName    = "/tts.v1.TTSService/Synthesize"
+	TTSService_BatchGetAudio_FullMethodName = "/tts.v1.TTSService/BatchGetAudio"
+	TTSService_GetJob_FullMethodName        = "/tts.v1.TTSService/GetJob"
+	TTSService_ListVoices_FullMethodName    = "/tts.v1.TTSService/ListVoices"
+)
+
+// TTSServiceClient is the client API for TTSService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// TTSService mirrors the REST API for typed clients. Requests authenticate with
+// the same API keys, sent as "x-api-key" or "authorization: Bearer <key>" metadata.
+type TTSServiceClient interface {
+	// Process synthesizes every word in one provider request and stores a clip per
+	// word. It returns once the job has finished, with the outcome of each word in
+	// its results. To follow a job while it runs, start it with the REST API's
+	// async flag and poll GetJob.
+	Process(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*Job, error)
+	// Synthesize speaks a single word or sentence without storing it, streaming
+	// WAV audio as the provider produces it. The WAV header may not declare a length.
+	Synthesize(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioChunk], error)
+	// BatchGetAudio streams one message per requested clip, in request order. At
+	// most 100 context_ids may be requested per call.
+	BatchGetAudio(ctx context.Context, in *BatchGetAudioRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioClip], error)
+	// GetJob returns the state of a job started by Process or the REST API.
+	GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error)
+	// ListVoices returns the voices configured for each enabled engine.
+	ListVoices(ctx context.Context, in *ListVoicesRequest, opts ...grpc.CallOption) (*ListVoicesResponse, error)
+}
+
+type tTSServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewTTSServiceClient(cc grpc.ClientConnInterface) TTSServiceClient {
+	return &tTSServiceClient{cc}
+}
+
+func (c *tTSServiceClient) Process(ctx context.Context, in *ProcessRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, TTSService_Process_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tTSServiceClient) Synthesize(ctx context.Context, in *SynthesizeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TTSService_ServiceDesc.Streams[0], TTSService_Synthesize_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SynthesizeRequest, AudioChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TTSService_SynthesizeClient = grpc.ServerStreamingClient[AudioChunk]
+
+func (c *tTSServiceClient) BatchGetAudio(ctx context.Context, in *BatchGetAudioRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[AudioClip], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &TTSService_ServiceDesc.Streams[1], TTSService_BatchGetAudio_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BatchGetAudioRequest, AudioClip]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TTSService_BatchGetAudioClient = grpc.ServerStreamingClient[AudioClip]
+
+func (c *tTSServiceClient) GetJob(ctx context.Context, in *GetJobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, TTSService_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tTSServiceClient) ListVoices(ctx context.Context, in *ListVoicesRequest, opts ...grpc.CallOption) (*ListVoicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVoicesResponse)
+	err := c.cc.Invoke(ctx, TTSService_ListVoices_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// TTSServiceServer is the server API for TTSService service.
+// All implementations must embed UnimplementedTTSServiceServer
+// for forward compatibility.
+//
+// TTSService mirrors the REST API for typed clients. Requests authenticate with
+// the same API keys, sent as "x-api-key" or "authorization: Bearer <key>" metadata.
+type TTSServiceServer interface {
+	// Process synthesizes every word in one provider request and stores a clip per
+	// word. It returns once the job has finished, with the outcome of each word in
+	// its results. To follow a job while it runs, start it with the REST API's
+	// async flag and poll GetJob.
+	Process(context.Context, *ProcessRequest) (*Job, error)
+	// Synthesize speaks a single word or sentence without storing it, streaming
+	// WAV audio as the provider produces it. The WAV header may not declare a length.
+	Synthesize(*SynthesizeRequest, grpc.ServerStreamingServer[AudioChunk]) error
+	// BatchGetAudio streams one message per requested clip, in request order. At
+	// most 100 context_ids may be requested per call.
+	BatchGetAudio(*BatchGetAudioRequest, grpc.ServerStreamingServer[AudioClip]) error
+	// GetJob returns the state of a job started by Process or the REST API.
+	GetJob(context.Context, *GetJobRequest) (*Job, error)
+	// ListVoices returns the voices configured for each enabled engine.
+	ListVoices(context.Context, *ListVoicesRequest) (*ListVoicesResponse, error)
+	mustEmbedUnimplementedTTSServiceServer()
+}
+
+// UnimplementedTTSServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedTTSServiceServer struct{}
+
+func (UnimplementedTTSServiceServer) Process(context.Context, *ProcessRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Process not implemented")
+}
+func (UnimplementedTTSServiceServer) Synthesize(*SynthesizeRequest, grpc.ServerStreamingServer[AudioChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Synthesize not implemented")
+}
+func (UnimplementedTTSServiceServer) BatchGetAudio(*BatchGetAudioRequest, grpc.ServerStreamingServer[AudioClip]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchGetAudio not implemented")
+}
+func (UnimplementedTTSServiceServer) GetJob(context.Context, *GetJobRequest) (*Job, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedTTSServiceServer) ListVoices(context.Context, *ListVoicesRequest) (*ListVoicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVoices not implemented")
+}
+func (UnimplementedTTSServiceServer) mustEmbedUnimplementedTTSServiceServer() {}
+func (UnimplementedTTSServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeTTSServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to TTSServiceServer will
+// result in compilation errors.
+type UnsafeTTSServiceServer interface {
+	mustEmbedUnimplementedTTSServiceServer()
+}
+
+func RegisterTTSServiceServer(s grpc.ServiceRegistrar, srv TTSServiceServer) {
+	// If the following call pancis, it indicates UnimplementedTTSServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&TTSService_ServiceDesc, srv)
+}
+
+func _TTSService_Process_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProcessRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TTSServiceServer).Process(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TTSService_Process_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TTSServiceServer).Process(ctx, req.(*ProcessRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TTSService_Synthesize_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SynthesizeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TTSServiceServer).Synthesize(m, &grpc.GenericServerStream[SynthesizeRequest, AudioChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TTSService_SynthesizeServer = grpc.ServerStreamingServer[AudioChunk]
+
+func _TTSService_BatchGetAudio_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BatchGetAudioRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(TTSServiceServer).BatchGetAudio(m, &grpc.GenericServerStream[BatchGetAudioRequest, AudioClip]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type TTSService_BatchGetAudioServer = grpc.ServerStreamingServer[AudioClip]
+
+func _TTSService_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TTSServiceServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TTSService_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TTSServiceServer).GetJob(ctx, req.(*GetJobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TTSService_ListVoices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVoicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TTSServiceServer).ListVoices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TTSService_ListVoices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TTSServiceServer).ListVoices(ctx, req.(*ListVoicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// TTSService_ServiceDesc is the grpc.ServiceDesc for TTSService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var TTSService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tts.v1.TTSService",
+	HandlerType: (*TTSServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Process",
+			Handler:    _TTSService_Process_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _TTSService_GetJob_Handler,
+		},
+		{
+			MethodName: "ListVoices",
+			Handler:    _TTSService_ListVoices_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Synthesize",
+			Handler:       _TTSService_Synthesize_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "BatchGetAudio",
+			Handler:       _TTSService_BatchGetAudio_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tts/v1/tts.proto",
+}
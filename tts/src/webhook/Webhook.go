@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"tts/src/logging"
+	"tts/src/metrics"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the secret given in the request, so receivers
+// can verify the sender and reject replays of old timestamps.
+const (
+	HeaderSignature = "X-TTS-Signature"
+	HeaderTimestamp = "X-TTS-Timestamp"
+	HeaderDelivery  = "X-TTS-Delivery"
+	HeaderEvent     = "X-TTS-Event"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Attempt is one POST of a delivery
+type Attempt struct {
+	Attempt    int       `json:"attempt"`
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+// Delivery is the log of sending one event to a callback URL
+type Delivery struct {
+	ID       string    `json:"id"`
+	JobID    string    `json:"job_id"`
+	Event    string    `json:"event"`
+	URL      string    `json:"url"`
+	Status   string    `json:"status"`
+	Attempts []Attempt `json:"attempts"`
+}
+
+type Options struct {
+	// MaxAttempts is how often a delivery is tried before giving up
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry, doubling after each failure
+	InitialBackoff time.Duration
+	// Timeout bounds each attempt
+	Timeout time.Duration
+	// LogSize is how many deliveries are kept for lookups
+	LogSize int
+	// AllowedHosts limits callback URLs to these hosts, or their subdomains
+	// when written as .example.com. Empty allows any host.
+	AllowedHosts []string
+	// AllowPrivateNetworks permits deliveries to loopback, private and
+	// link-local addresses
+	AllowPrivateNetworks bool
+}
+
+// ErrURLNotAllowed is returned for callback URLs the dispatcher will not deliver to
+var ErrURLNotAllowed = errors.New("callback url is not allowed")
+
+// Dispatcher delivers signed events in the background, retrying failures
+// with exponential backoff, and keeps a log of recent deliveries. The log and
+// pending retries live in memory only and are lost when the process stops.
+type Dispatcher struct {
+	client *http.Client
+	opts   Options
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu         sync.Mutex
+	deliveries map[string]*Delivery
+	order      []string
+}
+
+func NewDispatcher(opts Options) *Dispatcher {
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		opts:       opts,
+		ctx:        ctx,
+		cancel:     cancel,
+		deliveries: make(map[string]*Delivery),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !opts.AllowPrivateNetworks {
+		// Checked on the resolved address, so a public name pointing at an
+		// internal one is refused too. Proxies would hide the target.
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   refusePrivate,
+		}).DialContext
+	}
+	d.client = &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return d.CheckURL(req.URL.String())
+		},
+	}
+	return d
+}
+
+// CheckURL returns ErrURLNotAllowed unless rawURL is an http(s) URL whose host
+// is allowed and, unless private networks are, not a private address
+func (d *Dispatcher) CheckURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("%w: %q is not an http url", ErrURLNotAllowed, rawURL)
+	}
+	host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+
+	if len(d.opts.AllowedHosts) > 0 && !hostAllowed(host, d.opts.AllowedHosts) {
+		return fmt.Errorf("%w: host %q is not in webhooks.allowed_hosts", ErrURLNotAllowed, host)
+	}
+	if d.opts.AllowPrivateNetworks {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: host %q is loopback", ErrURLNotAllowed, host)
+	}
+	if ip := net.ParseIP(host); ip != nil && privateIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrURLNotAllowed, ip)
+	}
+	return nil
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if host == pattern || (strings.HasPrefix(pattern, ".") && strings.HasSuffix(host, pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// privateIP reports whether ip is loopback, private, link-local, multicast or
+// unspecified, none of which callbacks may reach by default
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// refusePrivate is a net.Dialer Control refusing connections to private addresses
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+// Send queues payload for delivery to url as event. It only fails when the
+// payload cannot be encoded.
+func (d *Dispatcher) Send(jobID, event, url, secret string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	delivery := &Delivery{ID: logging.NewRequestID(), JobID: jobID, Event: event, URL: url, Status: DeliveryPending}
+	d.mu.Lock()
+	d.deliveries[jobID] = delivery
+	d.order = append(d.order, jobID)
+	for len(d.order) > d.opts.LogSize {
+		delete(d.deliveries, d.order[0])
+		d.order = d.order[1:]
+	}
+	d.mu.Unlock()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(delivery, secret, body)
+	}()
+	return nil
+}
+
+// Delivery returns a copy of the logged delivery for a job
+func (d *Dispatcher) Delivery(jobID string) (Delivery, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delivery, ok := d.deliveries[jobID]
+	if !ok {
+		return Delivery{}, false
+	}
+	copied := *delivery
+	copied.Attempts = append([]Attempt(nil), delivery.Attempts...)
+	return copied, true
+}
+
+// Close abandons pending retries and waits for attempts in flight
+func (d *Dispatcher) Close() {
+	d.cancel()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(delivery *Delivery, secret string, body []byte) {
+	logger := slog.With("delivery", delivery.ID, "job", delivery.JobID, "url", delivery.URL)
+	backoff := d.opts.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		statusCode, err := d.post(delivery, secret, body)
+		retry := err != nil && retryable(statusCode) && !errors.Is(err, ErrURLNotAllowed) && attempt < d.opts.MaxAttempts
+
+		result := Attempt{Attempt: attempt, At: start.UTC(), StatusCode: statusCode, DurationMs: time.Since(start).Milliseconds()}
+		status := DeliveryDelivered
+		switch {
+		case retry:
+			status = DeliveryPending
+			result.Error = err.Error()
+			metrics.WebhookAttempt("retry")
+			logger.Warn("webhook delivery failed, retrying", "attempt", attempt, "status", statusCode, "error", err)
+		case err != nil:
+			status = DeliveryFailed
+			result.Error = err.Error()
+			metrics.WebhookAttempt("failed")
+			logger.Error("webhook delivery failed", "attempt", attempt, "status", statusCode, "error", err)
+		default:
+			metrics.WebhookAttempt("delivered")
+			logger.Info("webhook delivered", "attempt", attempt, "status", statusCode)
+		}
+
+		d.mu.Lock()
+		delivery.Attempts = append(delivery.Attempts, result)
+		delivery.Status = status
+		d.mu.Unlock()
+
+		if !retry {
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-d.ctx.Done():
+			d.mu.Lock()
+			delivery.Status = DeliveryFailed
+			d.mu.Unlock()
+			logger.Warn("webhook delivery abandoned on shutdown", "attempts", attempt)
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) post(delivery *Delivery, secret string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("callback answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryable reports whether a failed attempt may succeed later. Network errors
+// (no status), timeouts, rate limits and server errors are retried; other
+// client errors mean the receiver rejected the event.
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}